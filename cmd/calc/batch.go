@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/shmsr/x/pkg/expr"
+)
+
+// runBatch evaluates the formula src once per row of the CSV document read
+// from in, binding each variable to the cell in the column its header names.
+// Rows are copied to out with the result appended in a column named column.
+// A row that fails gets an empty result and is reported to errs instead of
+// stopping the run; runBatch reports whether any row failed.
+func runBatch(src string, in io.Reader, out, errs io.Writer, column string) (bool, error) {
+	r := csv.NewReader(in)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err == io.EOF {
+		return false, errors.New("csv: missing header")
+	}
+	if err != nil {
+		return false, fmt.Errorf("csv: %w", err)
+	}
+
+	// Every column is bound up front so that the parser rejects variables
+	// the document doesn't have
+	env := expr.Env{}
+	for _, name := range header {
+		env[name] = nil
+	}
+	e, err := parseInput(src, env, false)
+	if err != nil {
+		return false, err
+	}
+
+	w := csv.NewWriter(out)
+	if err := w.Write(append(header, column)); err != nil {
+		return false, err
+	}
+	failed := false
+	for row := 1; ; row++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		var pe *csv.ParseError
+		if errors.As(err, &pe) {
+			fmt.Fprintf(errs, "calc: row %d: %v\n", row, err)
+			failed = true
+			continue
+		}
+		if err != nil {
+			return failed, err
+		}
+
+		result, err := evalRow(e, env, header, record)
+		if err != nil {
+			fmt.Fprintf(errs, "calc: row %d: %v\n", row, err)
+			failed = true
+		}
+		if err := w.Write(append(record, result)); err != nil {
+			return failed, err
+		}
+	}
+	w.Flush()
+	return failed, w.Error()
+}
+
+// evalRow binds the cells of record to the variables named by header and
+// evaluates e, returning the formatted result. Cells are read as constant
+// expressions, so "1/3" and "5 km" work as they do on the command line.
+func evalRow(e expr.Node, env expr.Env, header, record []string) (string, error) {
+	if len(record) != len(header) {
+		return "", fmt.Errorf("expected %d fields, got %d", len(header), len(record))
+	}
+	cellErrs := map[string]error{}
+	for i, name := range header {
+		v, err := cellValue(record[i])
+		if err != nil {
+			cellErrs[name] = fmt.Errorf("invalid %s value %q: %v", name, record[i], err)
+		}
+		env[name] = v
+	}
+
+	v, err := evalMode(e, env)
+	if err != nil {
+		// A cell that didn't parse leaves its variable unbound; blame the cell
+		var ue *expr.UndefinedError
+		if errors.As(err, &ue) && cellErrs[ue.Name] != nil {
+			return "", cellErrs[ue.Name]
+		}
+		return "", err
+	}
+	return expr.Format(v), nil
+}
+
+// cellValue evaluates the text of a cell in the selected mode
+func cellValue(cell string) (interface{}, error) {
+	e, err := expr.ParseEnv(cell, nil)
+	if err != nil {
+		return nil, err
+	}
+	return evalMode(e, nil)
+}
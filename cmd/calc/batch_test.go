@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	tests := map[string]struct {
+		src    string
+		column string
+		input  string
+		want   string // the CSV written to out
+		errs   string // prefix of the errors, empty for none
+		failed bool
+	}{
+		"result column": {
+			src: "x * y", column: "result",
+			input: "x,y\n2,3\n4,5\n",
+			want:  "x,y,result\n2,3,6\n4,5,20\n",
+		},
+		"named column": {
+			src: "distance / time to km/h", column: "speed",
+			input: "distance,time\n120 km,1.5 h\n",
+			want:  "distance,time,speed\n120 km,1.5 h,80 km/h\n",
+		},
+		"row error": {
+			src: "a / b", column: "result",
+			input:  "a,b\n1,0\n6,3\n",
+			want:   "a,b,result\n1,0,\n6,3,2\n",
+			errs:   "calc: row 1: division by zero in (a / b)\n",
+			failed: true,
+		},
+		"bad cell": {
+			src: "x + 1", column: "result",
+			input:  "x,y\nfoo,1\n2,bar\n",
+			want:   "x,y,result\nfoo,1,\n2,bar,3\n",
+			errs:   "calc: row 1: invalid x value \"foo\": ",
+			failed: true,
+		},
+		"field count": {
+			src: "x", column: "result",
+			input:  "x\n1,2\n\"3\"\n",
+			want:   "x,result\n1,2,\n3,3\n",
+			errs:   "calc: row 1: expected 1 fields, got 2\n",
+			failed: true,
+		},
+		"unparsable row": {
+			src: "x", column: "result",
+			input:  "x\n\"1\n",
+			want:   "x,result\n",
+			errs:   "calc: row 1: ",
+			failed: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out, errs bytes.Buffer
+			failed, err := runBatch(tc.src, strings.NewReader(tc.input), &out, &errs, tc.column)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if failed != tc.failed {
+				t.Fatalf("expected failed: %v, got: %v (%q)", tc.failed, failed, errs.String())
+			}
+			if got := out.String(); got != tc.want {
+				t.Fatalf("expected: %q, got: %q", tc.want, got)
+			}
+			if got := errs.String(); !strings.HasPrefix(got, tc.errs) || tc.errs == "" && got != "" {
+				t.Fatalf("expected errors starting with: %q, got: %q", tc.errs, got)
+			}
+		})
+	}
+}
+
+func TestBatchErrors(t *testing.T) {
+	tests := map[string]struct {
+		src   string
+		input string
+	}{
+		"missing header": {src: "x", input: ""},
+		"unknown column": {src: "x + z", input: "x,y\n1,2\n"},
+		"syntax error":   {src: "x +", input: "x\n1\n"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out, errs bytes.Buffer
+			if _, err := runBatch(tc.src, strings.NewReader(tc.input), &out, &errs, "result"); err == nil {
+				t.Fatalf("expected an error, got: %q", out.String())
+			}
+		})
+	}
+}
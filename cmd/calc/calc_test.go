@@ -0,0 +1,201 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/shmsr/x/pkg/expr"
+)
+
+// evaler is the float64 evaluation operation every generated node implements
+type evaler interface {
+	Eval(env expr.Env) float64
+}
+
+// seed replays the random trees of a failed run when set
+var seed = flag.Int64("seed", 0, "<int>: seed of the random trees, 0 for a new one")
+
+// maxDepth bounds the height of generated trees
+const maxDepth = 6
+
+// seeds holds the subtrees of createNewExpr's tree, which the generator
+// mixes into the random trees it builds
+var seeds = func() []expr.Node {
+	var s []expr.Node
+	expr.Inspect(createNewExpr(), func(n expr.Node) bool {
+		if n != nil {
+			s = append(s, n)
+		}
+		return true
+	})
+	return s
+}()
+
+// binaryNodes builds each binary node type from its operands
+var binaryNodes = []func(l, r expr.Node) expr.Node{
+	func(l, r expr.Node) expr.Node { return &expr.BinaryPlus{Left: l, Right: r} },
+	func(l, r expr.Node) expr.Node { return &expr.BinaryMinus{Left: l, Right: r} },
+	func(l, r expr.Node) expr.Node { return &expr.BinaryMultiply{Left: l, Right: r} },
+	func(l, r expr.Node) expr.Node { return &expr.BinaryDivide{Left: l, Right: r} },
+	func(l, r expr.Node) expr.Node { return &expr.BinaryModulo{Left: l, Right: r} },
+	func(l, r expr.Node) expr.Node { return &expr.BinaryPower{Left: l, Right: r} },
+}
+
+// randomTree builds a tree of constants, negations and binary nodes at
+// most depth high. Constants are negative about a third of the time, which
+// covers the rendering of negative bases and operands.
+func randomTree(r *rand.Rand, depth int) expr.Node {
+	if depth <= 1 || r.Intn(4) == 0 {
+		var v float64
+		switch r.Intn(4) {
+		case 0:
+			return seeds[r.Intn(len(seeds))]
+		case 1:
+			v = float64(r.Intn(10))
+		default:
+			v = math.Round(r.Float64()*1e4) / 100
+		}
+		if r.Intn(3) == 0 {
+			v = -v
+		}
+		return &expr.Constant{Value: v}
+	}
+	if r.Intn(5) == 0 {
+		return &expr.UnaryMinus{Operand: randomTree(r, depth-1)}
+	}
+	build := binaryNodes[r.Intn(len(binaryNodes))]
+	return build(randomTree(r, depth-1), randomTree(r, depth-1))
+}
+
+// tree wraps a node so that testing/quick can generate it
+type tree struct {
+	expr.Node
+}
+
+func (tree) Generate(r *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(tree{randomTree(r, 1+r.Intn(maxDepth))})
+}
+
+func (t tree) String() string {
+	return expr.String(t.Node)
+}
+
+// check runs property f on createNewExpr's tree and its subtrees, then on
+// random trees from a seed that is logged so a failure can be replayed
+func check(t *testing.T, f interface{}) {
+	t.Helper()
+	fn := reflect.ValueOf(f)
+	for _, n := range seeds {
+		args := make([]reflect.Value, fn.Type().NumIn())
+		for i := range args {
+			args[i] = reflect.ValueOf(tree{n})
+		}
+		if !fn.Call(args)[0].Bool() {
+			t.Fatalf("property fails on seed %s", expr.String(n))
+		}
+	}
+
+	s := *seed
+	if s == 0 {
+		s = time.Now().UnixNano()
+	}
+	t.Logf("seed: %d", s)
+	cfg := &quick.Config{MaxCount: 1000, Rand: rand.New(rand.NewSource(s))}
+	if err := quick.Check(f, cfg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// same reports whether x and y are the same float64, counting NaNs as equal
+func same(x, y float64) bool {
+	return x == y || math.IsNaN(x) && math.IsNaN(y)
+}
+
+// near reports whether x and y agree within a relative tolerance of scale
+func near(x, y, scale float64) bool {
+	if math.IsInf(scale, 0) || math.IsNaN(scale) {
+		return true
+	}
+	return math.Abs(x-y) <= 1e-9*scale+1e-300
+}
+
+func TestCommutativity(t *testing.T) {
+	tests := map[string]func(l, r expr.Node) expr.Node{
+		"plus":     binaryNodes[0],
+		"multiply": binaryNodes[2],
+	}
+
+	for name, build := range tests {
+		t.Run(name, func(t *testing.T) {
+			check(t, func(a, b tree) bool {
+				return same(build(a.Node, b.Node).(evaler).Eval(nil), build(b.Node, a.Node).(evaler).Eval(nil))
+			})
+		})
+	}
+}
+
+func TestDistributivity(t *testing.T) {
+	check(t, func(a, b, c tree) bool {
+		x, y, z := a.Node.(evaler).Eval(nil), b.Node.(evaler).Eval(nil), c.Node.(evaler).Eval(nil)
+		left := (&expr.BinaryMultiply{Left: a.Node, Right: &expr.BinaryPlus{Left: b.Node, Right: c.Node}}).Eval(nil)
+		right := (&expr.BinaryPlus{
+			Left:  &expr.BinaryMultiply{Left: a.Node, Right: b.Node},
+			Right: &expr.BinaryMultiply{Left: a.Node, Right: c.Node},
+		}).Eval(nil)
+		// Rounding in x*y + x*z grows with the terms, not with the result
+		return near(left, right, math.Abs(x*y)+math.Abs(x*z))
+	})
+}
+
+func TestToString(t *testing.T) {
+	check(t, func(a tree) bool {
+		s := a.Node.(interface{ ToString() string }).ToString()
+		if s != expr.String(a.Node) {
+			return false
+		}
+		// Only binary nodes are wrapped in parentheses; a negation is
+		// prefixed with '-'
+		switch a.Node.(type) {
+		case *expr.Constant, *expr.UnaryMinus:
+		default:
+			if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+				return false
+			}
+		}
+		// Fully parenthesized output reads back as the same tree
+		n, err := expr.Parse(s)
+		return err == nil && expr.String(n) == s && same(n.(evaler).Eval(nil), a.Node.(evaler).Eval(nil))
+	})
+}
+
+func TestNoPanics(t *testing.T) {
+	check(t, func(a tree) bool {
+		want := a.Node.(evaler).Eval(nil)
+		if got, err := expr.EvalFloat(a.Node, nil); err == nil && !same(got, want) {
+			return false
+		}
+		_, _ = expr.Eval(a.Node, nil)
+		_, _ = expr.EvalRat(a.Node, nil)
+		_, _ = expr.EvalBig(a.Node, nil, 64)
+		_ = expr.Simplify(a.Node)
+		_, _ = expr.RPN(a.Node)
+		_, _ = expr.LaTeX(a.Node)
+		_, _ = expr.SExpr(a.Node)
+		_, _ = expr.DOT(a.Node)
+		if _, err := expr.Derive(a.Node, "x"); err != nil {
+			return false
+		}
+		p, err := expr.Compile(a.Node)
+		if err != nil {
+			return false
+		}
+		got, err := expr.NewVM(p).Run(nil)
+		return err != nil || same(got, want)
+	})
+}
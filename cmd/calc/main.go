@@ -0,0 +1,303 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/shmsr/x/pkg/expr"
+)
+
+var (
+	demo       bool
+	mode       string
+	prec       uint
+	simplified bool
+	diff       string
+	output     string
+	input      string
+	csvPath    string
+	column     string
+)
+
+// Evaluation modes selectable with -mode
+const (
+	modeFloat = "float"
+	modeRat   = "rat"
+	modeBig   = "big"
+)
+
+const (
+	usageDemo     = "<bool>: evaluate the built-in tree from createNewExpr instead of parsing input"
+	usageMode     = "<string>: evaluation mode: float (float64), rat (exact rationals) or big (big.Float)"
+	usagePrec     = "<uint>: mantissa precision in bits for -mode big"
+	usageSimplify = "<bool>: print each expression next to its simplified form before the result"
+	usageDiff     = "<string>: print the derivative with respect to the named variable instead of evaluating"
+	usageFormat   = "<string>: print the tree instead of evaluating it, as infix, rpn, latex, dot, json, sexpr or bytecode"
+	usageInput    = "<string>: syntax of the input: infix, json or sexpr; json and sexpr read all of STDIN as one tree"
+	usageCSV      = "<string>: evaluate the expression once per row of this CSV file (- for STDIN), whose header names the variables"
+	usageColumn   = "<string>: name of the result column -csv appends to each row"
+
+	defaultDemo     = false
+	defaultMode     = modeFloat
+	defaultPrec     = 256
+	defaultSimplify = false
+	defaultDiff     = ""
+	defaultFormat   = ""
+	defaultInput    = "infix"
+	defaultCSV      = ""
+	defaultColumn   = "result"
+)
+
+// evalMode evaluates e with the variables bound in env in the selected
+// mode, returning a float64, quantity, *big.Rat or *big.Float, or a bool
+// for comparisons
+func evalMode(e expr.Node, env expr.Env) (interface{}, error) {
+	switch mode {
+	case modeRat:
+		return expr.EvalRat(e, env)
+	case modeBig:
+		return expr.EvalBig(e, env, prec)
+	}
+	return expr.Eval(e, env)
+}
+
+// render prints e in one of the -format output formats
+func render(e expr.Node, format string) (string, error) {
+	switch format {
+	case "infix":
+		return expr.String(e), nil
+	case "rpn":
+		return expr.RPN(e)
+	case "latex":
+		return expr.LaTeX(e)
+	case "dot":
+		return expr.DOT(e)
+	case "json":
+		return expr.JSON(e)
+	case "sexpr":
+		return expr.SExpr(e)
+	case "bytecode":
+		p, err := expr.Compile(e)
+		if err != nil {
+			return "", err
+		}
+		return p.String(), nil
+	}
+	return "", fmt.Errorf("unknown format %q", format)
+}
+
+// parseInput parses src written in the -input syntax. Infix input may only
+// use the variables bound in env unless free is set; decoded trees may
+// always contain unbound ones.
+func parseInput(src string, env expr.Env, free bool) (expr.Node, error) {
+	switch input {
+	case "json":
+		return expr.DecodeJSON([]byte(src))
+	case "sexpr":
+		return expr.ParseSExpr(src)
+	}
+	if free {
+		return expr.Parse(src)
+	}
+	return expr.ParseEnv(src, env)
+}
+
+// run parses and evaluates a single expression, printing the result to w
+func run(w io.Writer, src string) error {
+	if diff != "" || output != "" {
+		e, err := parseInput(src, nil, true)
+		if err != nil {
+			return err
+		}
+		if diff != "" {
+			return printDerivative(w, e, diff)
+		}
+		out, err := render(e, output)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, out)
+		return nil
+	}
+
+	e, err := parseInput(src, nil, false)
+	if err != nil {
+		return err
+	}
+	if simplified {
+		printSimplified(w, e)
+	}
+	v, err := evalMode(e, nil)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, expr.Format(v))
+	return nil
+}
+
+// printSimplified prints e next to its simplified form
+func printSimplified(w io.Writer, e expr.Node) {
+	fmt.Fprintf(w, "%s => %s\n", expr.String(e), expr.String(expr.Simplify(e)))
+}
+
+// printDerivative prints the derivative of e with respect to x, simplified
+// if -simplify is set and rendered in the -format output format if any
+func printDerivative(w io.Writer, e expr.Node, x string) error {
+	d, err := expr.Derive(e, x)
+	if err != nil {
+		return err
+	}
+	if simplified {
+		d = expr.Simplify(d)
+	}
+	if output != "" {
+		out, err := render(d, output)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, out)
+		return nil
+	}
+	fmt.Fprintf(w, "d/d%s %s = %s\n", x, expr.String(e), expr.String(d))
+	return nil
+}
+
+// report prints err to w, pointing at the offending column for syntax errors
+func report(w io.Writer, src string, err error) {
+	var se *expr.SyntaxError
+	if errors.As(err, &se) && !strings.Contains(src, "\n") {
+		fmt.Fprintf(w, "  %s\n  %s^\n", src, strings.Repeat(" ", se.Pos-1))
+	}
+	fmt.Fprintln(w, "calc:", err)
+}
+
+// createNewExpr builds 1 + (2 - (3 * (4 / 1))) by hand from the node types
+func createNewExpr() *expr.BinaryPlus {
+	return &expr.BinaryPlus{
+		Left: &expr.Constant{Value: 1},
+		Right: &expr.BinaryMinus{
+			Left: &expr.Constant{Value: 2},
+			Right: &expr.BinaryMultiply{
+				Left: &expr.Constant{Value: 3},
+				Right: &expr.BinaryDivide{
+					Left:  &expr.Constant{Value: 4},
+					Right: &expr.Constant{Value: 1},
+				},
+			},
+		},
+	}
+}
+
+func main() {
+	flag.BoolVar(&demo, "demo", defaultDemo, usageDemo)
+	flag.StringVar(&mode, "mode", defaultMode, usageMode)
+	flag.UintVar(&prec, "prec", defaultPrec, usagePrec)
+	flag.BoolVar(&simplified, "simplify", defaultSimplify, usageSimplify)
+	flag.StringVar(&diff, "diff", defaultDiff, usageDiff)
+	flag.StringVar(&output, "format", defaultFormat, usageFormat)
+	flag.StringVar(&input, "input", defaultInput, usageInput)
+	flag.StringVar(&csvPath, "csv", defaultCSV, usageCSV)
+	flag.StringVar(&column, "column", defaultColumn, usageColumn)
+	flag.Parse()
+
+	switch mode {
+	case modeFloat, modeRat, modeBig:
+	default:
+		fmt.Fprintf(os.Stderr, "calc: unknown mode %q. Try (-h) or (--help) flag\n", mode)
+		os.Exit(2)
+	}
+	switch output {
+	case "", "infix", "rpn", "latex", "dot", "json", "sexpr", "bytecode":
+	default:
+		fmt.Fprintf(os.Stderr, "calc: unknown format %q. Try (-h) or (--help) flag\n", output)
+		os.Exit(2)
+	}
+	switch input {
+	case "infix", "json", "sexpr":
+	default:
+		fmt.Fprintf(os.Stderr, "calc: unknown input syntax %q. Try (-h) or (--help) flag\n", input)
+		os.Exit(2)
+	}
+	if prec == 0 || prec > big.MaxPrec {
+		fmt.Fprintf(os.Stderr, "calc: precision must be between 1 and %d bits\n", uint(big.MaxPrec))
+		os.Exit(2)
+	}
+
+	if demo {
+		tree := createNewExpr()
+		fmt.Println("Eval: ", tree.Eval(nil))                         // Eval:  -9
+		fmt.Println("String: ", tree.ToString())                      // String:  (1 + (2 - (3 * (4 / 1))))
+		fmt.Println("Simplified: ", expr.String(expr.Simplify(tree))) // Simplified:  -9
+		return
+	}
+
+	if csvPath != "" {
+		if flag.NArg() == 0 || diff != "" || output != "" {
+			fmt.Fprintln(os.Stderr, "calc: -csv needs an expression to evaluate and can't be combined with -diff or -format")
+			os.Exit(2)
+		}
+		in := os.Stdin
+		if csvPath != "-" {
+			f, err := os.Open(csvPath)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "calc:", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			in = f
+		}
+		src := strings.Join(flag.Args(), " ")
+		failed, err := runBatch(src, in, os.Stdout, os.Stderr, column)
+		if err != nil {
+			report(os.Stderr, src, err)
+			os.Exit(1)
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.NArg() > 0 {
+		src := strings.Join(flag.Args(), " ")
+		if err := run(os.Stdout, src); err != nil {
+			report(os.Stderr, src, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Serialized trees may span lines, so they are read as a whole
+	if input != "infix" {
+		buf, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "calc:", err)
+			os.Exit(1)
+		}
+		src := strings.TrimSpace(string(buf))
+		if err := run(os.Stdout, src); err != nil {
+			report(os.Stderr, src, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Without arguments start a REPL, prompting only when attached to a terminal
+	prompt := ""
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		prompt = "> "
+	}
+	failed, err := newREPL(os.Stdout, os.Stderr).loop(os.Stdin, prompt)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "calc:", err)
+		failed = true
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/shmsr/x/pkg/expr"
+)
+
+// lastResult names the variable holding the most recent result
+const lastResult = "_"
+
+const replHelp = `Enter an expression to evaluate it, or "name = expression" to bind a variable.
+The last result is available as _.
+Commands:
+  :vars     list variables and their values
+  :funcs    list the available functions
+  :units    list the available units
+  :clear    remove all variables
+  :tree [f] print the last evaluated tree, optionally in a -format output format
+  :simplify print the last evaluated tree and its simplified form
+  :diff x   print the derivative of the last evaluated tree with respect to x
+  :history  list previous inputs
+  :help     show this message
+  :quit     leave calc`
+
+// repl holds the state carried between the lines of an interactive session
+type repl struct {
+	env     expr.Env
+	last    expr.Node
+	history []string
+	out     io.Writer
+	errs    io.Writer
+}
+
+// newREPL starts a session that prints results to out and errors to errs
+func newREPL(out, errs io.Writer) *repl {
+	return &repl{env: expr.Env{}, out: out, errs: errs}
+}
+
+// loop reads lines from in until EOF or :quit, printing prompt before each
+// one; a line that fails is reported to r.errs and the session goes on.
+// loop reports whether any line failed.
+func (r *repl) loop(in io.Reader, prompt string) (bool, error) {
+	failed := false
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(r.out, prompt)
+		if !scanner.Scan() {
+			break
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if line == ":quit" {
+			return failed, nil
+		}
+		if err := r.exec(line); err != nil {
+			report(r.errs, line, err)
+			failed = true
+		}
+	}
+	return failed, scanner.Err()
+}
+
+// exec runs a single line: either a command or an expression
+func (r *repl) exec(line string) error {
+	if strings.HasPrefix(line, ":") {
+		return r.command(line)
+	}
+	r.history = append(r.history, line)
+
+	name, e, err := expr.ParseAssignment(line, r.env)
+	if err != nil {
+		return err
+	}
+	if name == lastResult {
+		return &expr.SyntaxError{Pos: strings.Index(line, lastResult) + 1, Msg: fmt.Sprintf("cannot assign to %s", lastResult)}
+	}
+	if simplified {
+		printSimplified(r.out, e)
+	}
+	v, err := evalMode(e, r.env)
+	if err != nil {
+		return err
+	}
+	if name != "" {
+		r.env[name] = v
+	}
+	r.env[lastResult] = v
+	r.last = e
+	fmt.Fprintln(r.out, expr.Format(v))
+	return nil
+}
+
+func (r *repl) command(line string) error {
+	fields := strings.Fields(line)
+	if fields[0] == ":tree" && len(fields) == 2 {
+		if r.last == nil {
+			return fmt.Errorf("no expression evaluated yet")
+		}
+		out, err := render(r.last, fields[1])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(r.out, out)
+		return nil
+	}
+	if fields[0] == ":diff" {
+		if len(fields) != 2 {
+			return fmt.Errorf("usage: :diff <variable>")
+		}
+		if r.last == nil {
+			return fmt.Errorf("no expression evaluated yet")
+		}
+		return printDerivative(r.out, r.last, fields[1])
+	}
+
+	switch line {
+	case ":vars":
+		for _, name := range r.env.Names() {
+			fmt.Fprintf(r.out, "%s = %s\n", name, expr.Format(r.env[name]))
+		}
+	case ":funcs":
+		for _, name := range expr.FunctionNames() {
+			f, _ := expr.LookupFunction(name)
+			fmt.Fprintf(r.out, "%s (%s argument(s))\n", name, f.Arity())
+		}
+	case ":units":
+		for _, symbol := range expr.UnitSymbols() {
+			u, _ := expr.LookupUnit(symbol)
+			fmt.Fprintf(r.out, "%s = %s %s\n", symbol, strconv.FormatFloat(u.Scale, 'g', -1, 64), u.Dim)
+		}
+	case ":clear":
+		r.env = expr.Env{}
+		r.last = nil
+	case ":tree":
+		if r.last == nil {
+			return fmt.Errorf("no expression evaluated yet")
+		}
+		fmt.Fprintln(r.out, expr.String(r.last))
+	case ":simplify":
+		if r.last == nil {
+			return fmt.Errorf("no expression evaluated yet")
+		}
+		printSimplified(r.out, r.last)
+	case ":history":
+		for i, h := range r.history {
+			fmt.Fprintf(r.out, "%4d  %s\n", i+1, h)
+		}
+	case ":help":
+		fmt.Fprintln(r.out, replHelp)
+	default:
+		return fmt.Errorf("unknown command %s (try :help)", line)
+	}
+	return nil
+}
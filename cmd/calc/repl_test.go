@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestREPL(t *testing.T) {
+	tests := map[string]struct {
+		input  string
+		want   string // everything written to out
+		errs   string // everything written to errs
+		failed bool
+	}{
+		"last result": {
+			input: "2 * 3\n_ + 1\n_ * _\n",
+			want:  "6\n7\n49\n",
+		},
+		"assignment": {
+			input: "x = 2\ny = x ^ 3\nx + y\n",
+			want:  "2\n8\n10\n",
+		},
+		"reassignment": {
+			input: "x = 1\nx = x + 1\nx\n",
+			want:  "1\n2\n2\n",
+		},
+		"assign to last result": {
+			input:  "1\n_ = 5\n_\n",
+			want:   "1\n1\n",
+			errs:   "  _ = 5\n  ^\ncalc: column 1: cannot assign to _\n",
+			failed: true,
+		},
+		"undefined variable": {
+			input:  "x + 1\n",
+			errs:   "  x + 1\n  ^\ncalc: column 1: undefined variable \"x\"\n",
+			failed: true,
+		},
+		"unit shadowed by a variable": {
+			input:  "m = 2\n5 * m\n5 m\n3 ft to m\n:clear\n5 m\n",
+			want:   "2\n10\n0.9144 m\n5 m\n",
+			errs:   "  5 m\n    ^\ncalc: column 3: variable \"m\" shadows the unit of the same name\n",
+			failed: true,
+		},
+		"vars": {
+			input: "y = 2\nx = 1\n:vars\n",
+			want:  "2\n1\n_ = 1\nx = 1\ny = 2\n",
+		},
+		"clear": {
+			input:  "x = 1\n:clear\n:vars\nx\n",
+			want:   "1\n",
+			errs:   "  x\n  ^\ncalc: column 1: undefined variable \"x\"\n",
+			failed: true,
+		},
+		"tree": {
+			input: "x = 3\nx * (1 + 2)\n:tree\n:tree rpn\n",
+			want:  "3\n9\n(x * (1 + 2))\nx 1 2 + *\n",
+		},
+		"tree before any expression": {
+			input:  ":tree\n",
+			errs:   "calc: no expression evaluated yet\n",
+			failed: true,
+		},
+		"diff": {
+			input: "x = 3\nx ^ 2 + 1\n:diff x\n",
+			want:  "3\n10\nd/dx ((x ^ 2) + 1) = ((2 * (x ^ 1)) * 1)\n",
+		},
+		"diff usage": {
+			input:  "1\n:diff\n",
+			want:   "1\n",
+			errs:   "calc: usage: :diff <variable>\n",
+			failed: true,
+		},
+		"history": {
+			input: "1 + 1\n:vars\nx = 2\n:history\n",
+			want:  "2\n_ = 2\n2\n   1  1 + 1\n   2  x = 2\n",
+		},
+		"continues after an error": {
+			input:  "1 / 0\n:bogus\n2 + 2\n",
+			want:   "4\n",
+			errs:   "calc: division by zero in (1 / 0)\ncalc: unknown command :bogus (try :help)\n",
+			failed: true,
+		},
+		"quit": {
+			input: "1\n:quit\n2\n",
+			want:  "1\n",
+		},
+		"blank lines": {
+			input: "\n  \n1\n",
+			want:  "1\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out, errs bytes.Buffer
+			failed, err := newREPL(&out, &errs).loop(strings.NewReader(tc.input), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if failed != tc.failed {
+				t.Fatalf("expected failed: %v, got: %v (%q)", tc.failed, failed, errs.String())
+			}
+			if got := out.String(); got != tc.want {
+				t.Fatalf("expected: %q, got: %q", tc.want, got)
+			}
+			if got := errs.String(); got != tc.errs {
+				t.Fatalf("expected errors: %q, got: %q", tc.errs, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// checkEvery is how many elements withContext reduces between checks for
+// cancellation
+const checkEvery = 1 << 16
+
+// contextReduction is a reduction whose chunks can fail or be cancelled:
+// mapChunk returns an error instead of a result when it can't reduce its
+// chunk, and should return ctx.Err() soon after ctx is done
+type contextReduction[T, A any] struct {
+	identity A
+	mapChunk func(ctx context.Context, chunk []T) (A, error)
+	combine  func(a, b A) A
+}
+
+// withContext makes r cancellable by reducing each chunk checkEvery
+// elements at a time and stopping between them once ctx is done
+func withContext[T, A any](r reduction[T, A]) contextReduction[T, A] {
+	return contextReduction[T, A]{
+		identity: r.identity,
+		mapChunk: func(ctx context.Context, chunk []T) (A, error) {
+			acc := r.identity
+			for len(chunk) > 0 {
+				if err := ctx.Err(); err != nil {
+					return acc, err
+				}
+				n := min(len(chunk), checkEvery)
+				acc = r.combine(acc, r.mapChunk(chunk[:n]))
+				chunk = chunk[n:]
+			}
+			return acc, nil
+		},
+		combine: r.combine,
+	}
+}
+
+// mapReduceContext is mapReduce for a contextReduction
+func mapReduceContext[T, A any](ctx context.Context, slice []T, chunks int, r contextReduction[T, A]) (A, error) {
+	return reducePlanContext(ctx, slice, staticPlan(len(slice), chunks), r)
+}
+
+// reducePlanContext runs r over the slice as scheduled by p. The first
+// chunk to fail cancels the others and its error is returned; if ctx is
+// cancelled first, chunks that haven't started are skipped and ctx.Err()
+// is returned. Either way every goroutine has exited by the time it
+// returns.
+func reducePlanContext[T, A any](ctx context.Context, slice []T, p plan, r contextReduction[T, A]) (A, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		once     sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+	results := make([]A, len(p.spans))
+	schedule(p, func(i int) {
+		if err := ctx.Err(); err != nil {
+			fail(err)
+			return
+		}
+		s := p.spans[i]
+		v, err := r.mapChunk(ctx, slice[s.begin:s.end])
+		if err != nil {
+			fail(err)
+			return
+		}
+		results[i] = v
+	})
+	if firstErr != nil {
+		var zero A
+		return zero, firstErr
+	}
+
+	acc := r.identity
+	for _, v := range results {
+		acc = r.combine(acc, v)
+	}
+	return acc, nil
+}
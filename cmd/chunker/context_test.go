@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+var errSentinel = errors.New("sentinel element")
+
+// blocking fails on a chunk holding the element -1 and blocks every other
+// chunk until ctx is done
+func blocking() contextReduction[int64, int64] {
+	return contextReduction[int64, int64]{
+		mapChunk: func(ctx context.Context, chunk []int64) (int64, error) {
+			for _, v := range chunk {
+				if v == -1 {
+					return 0, errSentinel
+				}
+			}
+			<-ctx.Done()
+			return 0, ctx.Err()
+		},
+		combine: func(a, b int64) int64 { return a + b },
+	}
+}
+
+// reduceWithin runs f, failing t if it doesn't return within a second
+func reduceWithin(t *testing.T, f func() (int64, error)) (int64, error) {
+	t.Helper()
+	type result struct {
+		v   int64
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := f()
+		done <- result{v, err}
+	}()
+	select {
+	case r := <-done:
+		return r.v, r.err
+	case <-time.After(time.Second):
+		t.Fatal("reduction didn't return after being cancelled")
+		return 0, nil
+	}
+}
+
+// checkLeaks fails t if the goroutines started since there were before of
+// them don't exit shortly
+func checkLeaks(t *testing.T, before int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("leaked %d goroutines", runtime.NumGoroutine()-before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReduceContext(t *testing.T) {
+	tests := map[string]struct {
+		input  []int64
+		chunks int
+	}{
+		"small":  {input: sliceGenerator(10), chunks: 5},
+		"medium": {input: sliceGenerator(1004), chunks: 10},
+		"large":  {input: sliceGenerator(1000001), chunks: 15},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := plainSum(tc.input)
+			got, err := mapReduceContext(context.Background(), tc.input, tc.chunks, withContext(sum[int64]()))
+			if err != nil || got != s {
+				t.Fatalf("static: expected: %v, got: %v (%v)", s, got, err)
+			}
+			got, err = reducePlanContext(context.Background(), tc.input, dynamicPlan(len(tc.input), 4), withContext(sum[int64]()))
+			if err != nil || got != s {
+				t.Fatalf("dynamic: expected: %v, got: %v (%v)", s, got, err)
+			}
+		})
+	}
+}
+
+func TestReduceContextErrors(t *testing.T) {
+	input := make([]int64, 100000)
+	// The first chunk fails while the others wait for it to cancel them, and
+	// with fewer workers than chunks some are never started
+	input[0] = -1
+	plans := map[string]plan{
+		"static":  staticPlan(len(input), 10),
+		"dynamic": dynamicPlan(len(input), 2),
+	}
+
+	for name, p := range plans {
+		t.Run(name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+			_, err := reduceWithin(t, func() (int64, error) {
+				return reducePlanContext(context.Background(), input, p, blocking())
+			})
+			if !errors.Is(err, errSentinel) {
+				t.Fatalf("expected: %v, got: %v", errSentinel, err)
+			}
+			checkLeaks(t, before)
+		})
+	}
+}
+
+func TestReduceContextCancel(t *testing.T) {
+	// No element is -1, so every chunk blocks until the caller gives up
+	input := make([]int64, 100000)
+	plans := map[string]plan{
+		"static":  staticPlan(len(input), 10),
+		"dynamic": dynamicPlan(len(input), 2),
+	}
+
+	for name, p := range plans {
+		t.Run(name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(10*time.Millisecond, cancel)
+			_, err := reduceWithin(t, func() (int64, error) {
+				return reducePlanContext(ctx, input, p, blocking())
+			})
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected: %v, got: %v", context.Canceled, err)
+			}
+			checkLeaks(t, before)
+		})
+	}
+
+	t.Run("withContext", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := mapReduceContext(ctx, make([]int64, 1000001), 15, withContext(sum[int64]())); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected: %v, got: %v", context.Canceled, err)
+		}
+	})
+}
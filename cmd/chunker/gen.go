@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+)
+
+// Value distributions of a generator
+const (
+	distUniform     = "uniform"     // every value in range equally likely
+	distNormal      = "normal"      // centered in range, 3 standard deviations to either end
+	distZipf        = "zipf"        // small offsets from min much likelier than large ones
+	distEqual       = "equal"       // every value is max
+	distAlternating = "alternating" // uniform, but positive and negative in turn
+)
+
+// zipfS is the exponent of the zipf distribution, which must exceed 1
+const zipfS = 1.1
+
+// generator produces the same pseudo-random int64s every time for the
+// same seed
+type generator struct {
+	seed     int64
+	dist     string
+	min, max int64 // range of the values, inclusive
+}
+
+// newGenerator validates its arguments, returning a generator of values
+// in [lo, hi] drawn from dist
+func newGenerator(seed int64, dist string, lo, hi int64) (generator, error) {
+	g := generator{seed: seed, dist: dist, min: lo, max: hi}
+	if lo > hi {
+		return g, fmt.Errorf("empty range [%d, %d]", lo, hi)
+	}
+	switch dist {
+	case distUniform, distNormal, distZipf, distEqual:
+	case distAlternating:
+		if lo > 0 || hi < 0 {
+			return g, fmt.Errorf("alternating values need a range around 0, got [%d, %d]", lo, hi)
+		}
+	default:
+		return g, fmt.Errorf("unknown distribution %q", dist)
+	}
+	return g, nil
+}
+
+// values returns a function that yields the sequence of values
+func (g generator) values() func() int64 {
+	rng := rand.New(rand.NewSource(g.seed))
+	switch g.dist {
+	case distNormal:
+		mean := float64(g.min)/2 + float64(g.max)/2
+		sd := (float64(g.max) - float64(g.min)) / 6
+		return func() int64 {
+			v := math.Round(mean + rng.NormFloat64()*sd)
+			return int64(math.Max(float64(g.min), math.Min(v, float64(g.max))))
+		}
+	case distZipf:
+		z := rand.NewZipf(rng, zipfS, 1, uint64(g.max-g.min))
+		return func() int64 {
+			return g.min + int64(z.Uint64())
+		}
+	case distEqual:
+		return func() int64 {
+			return g.max
+		}
+	case distAlternating:
+		positive := false
+		return func() int64 {
+			positive = !positive
+			if positive {
+				return uniform(rng, 0, g.max)
+			}
+			return uniform(rng, g.min, 0)
+		}
+	}
+	return func() int64 {
+		return uniform(rng, g.min, g.max)
+	}
+}
+
+// uniform draws a value from [lo, hi] with every value equally likely
+func uniform(rng *rand.Rand, lo, hi int64) int64 {
+	n := uint64(hi-lo) + 1
+	switch {
+	case n == 0: // the whole of int64
+		return int64(rng.Uint64())
+	case n <= math.MaxInt64:
+		return lo + rng.Int63n(int64(n))
+	}
+	// More than half of the draws are in range
+	for {
+		if v := rng.Uint64(); v < n {
+			return lo + int64(v)
+		}
+	}
+}
+
+// slice generates size values
+func (g generator) slice(size int) []int64 {
+	next := g.values()
+	slice := make([]int64, size)
+	for i := range slice {
+		slice[i] = next()
+	}
+	return slice
+}
+
+// write writes n values to w as little-endian int64s
+func (g generator) write(w io.Writer, n int) error {
+	next := g.values()
+	bw := bufio.NewWriter(w)
+	var b [8]byte
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint64(b[:], uint64(next()))
+		if _, err := bw.Write(b[:]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
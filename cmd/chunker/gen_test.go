@@ -0,0 +1,87 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestGenerator(t *testing.T) {
+	tests := map[string]struct {
+		dist   string
+		lo, hi int64
+		check  func(i int, v int64) bool
+	}{
+		"uniform":     {dist: distUniform, lo: -998, hi: 998},
+		"full range":  {dist: distUniform, lo: math.MinInt64, hi: math.MaxInt64},
+		"upper half":  {dist: distUniform, lo: -1, hi: math.MaxInt64},
+		"normal":      {dist: distNormal, lo: 0, hi: 100},
+		"zipf":        {dist: distZipf, lo: 10, hi: 1000},
+		"equal":       {dist: distEqual, lo: 0, hi: 7, check: func(i int, v int64) bool { return v == 7 }},
+		"alternating": {dist: distAlternating, lo: -5, hi: 5, check: func(i int, v int64) bool { return i%2 == 0 && v >= 0 || i%2 == 1 && v <= 0 }},
+		"one value":   {dist: distUniform, lo: 3, hi: 3, check: func(i int, v int64) bool { return v == 3 }},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			g, err := newGenerator(*testSeed, tc.dist, tc.lo, tc.hi)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := g.slice(10000)
+			if again := g.slice(10000); !reflect.DeepEqual(got, again) {
+				t.Fatal("the same generator generated different values")
+			}
+			for i, v := range got {
+				if v < tc.lo || v > tc.hi || tc.check != nil && !tc.check(i, v) {
+					t.Fatalf("unexpected value %d at %d", v, i)
+				}
+			}
+		})
+	}
+}
+
+func TestGeneratorShape(t *testing.T) {
+	mean := func(s []int64) float64 {
+		var m float64
+		for _, v := range s {
+			m += float64(v) / float64(len(s))
+		}
+		return m
+	}
+
+	g, _ := newGenerator(*testSeed, distNormal, 0, 600)
+	if m := mean(g.slice(100000)); math.Abs(m-300) > 5 {
+		t.Fatalf("normal: expected a mean around 300, got: %v", m)
+	}
+	g, _ = newGenerator(*testSeed, distZipf, 0, 1000)
+	low := 0
+	for _, v := range g.slice(100000) {
+		if v < 100 {
+			low++
+		}
+	}
+	// About 77% of the values are expected in the lowest tenth of the range
+	if low < 70000 {
+		t.Fatalf("zipf: expected most values under 100, got: %d of 100000", low)
+	}
+}
+
+func TestGeneratorErrors(t *testing.T) {
+	tests := map[string]struct {
+		dist   string
+		lo, hi int64
+	}{
+		"empty range":    {dist: distUniform, lo: 1, hi: 0},
+		"unknown":        {dist: "poisson", lo: 0, hi: 10},
+		"no sign change": {dist: distAlternating, lo: 1, hi: 10},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := newGenerator(1, tc.dist, tc.lo, tc.hi); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
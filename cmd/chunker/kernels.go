@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+)
+
+// errOverflow is returned when a sum doesn't fit in an int64
+var errOverflow = errors.New("sum overflows int64")
+
+// unrolledSum adds up s with four independent accumulators, so that each
+// addition doesn't wait for the previous one and the CPU can run several at
+// once. gc doesn't vectorize the loop; the gain is instruction-level
+// parallelism only.
+func unrolledSum(s []int64) int64 {
+	var s0, s1, s2, s3 int64
+	i := 0
+	for ; i+4 <= len(s); i += 4 {
+		s0 += s[i]
+		s1 += s[i+1]
+		s2 += s[i+2]
+		s3 += s[i+3]
+	}
+	for ; i < len(s); i++ {
+		s0 += s[i]
+	}
+	return s0 + s1 + s2 + s3
+}
+
+// unrolled is sum[int64] with unrolledSum as the kernel
+func unrolled() reduction[int64, int64] {
+	return reduction[int64, int64]{
+		mapChunk: unrolledSum,
+		combine:  func(a, b int64) int64 { return a + b },
+	}
+}
+
+// int128 is a two's complement 128-bit integer. Adding up to 2^64 int64s
+// can't overflow it, so it holds their exact sum whatever the order.
+type int128 struct {
+	hi int64
+	lo uint64
+}
+
+// add adds v, sign-extended to 128 bits
+func (x int128) add(v int64) int128 {
+	lo, carry := bits.Add64(x.lo, uint64(v), 0)
+	return int128{x.hi + int64(carry) + v>>63, lo}
+}
+
+// plus adds y
+func (x int128) plus(y int128) int128 {
+	lo, carry := bits.Add64(x.lo, y.lo, 0)
+	return int128{x.hi + y.hi + int64(carry), lo}
+}
+
+// int64 returns x and whether it fits in an int64
+func (x int128) int64() (int64, bool) {
+	return int64(x.lo), x.hi == int64(x.lo)>>63
+}
+
+// big returns x as a big.Int
+func (x int128) big() *big.Int {
+	b := big.NewInt(x.hi)
+	b.Lsh(b, 64)
+	return b.Add(b, new(big.Int).SetUint64(x.lo))
+}
+
+// wideSum adds up s in 128 bits
+func wideSum(s []int64) int128 {
+	var x int128
+	for _, v := range s {
+		x = x.add(v)
+	}
+	return x
+}
+
+// wide sums int64s without overflowing
+func wide() reduction[int64, int128] {
+	return reduction[int64, int128]{
+		mapChunk: wideSum,
+		combine:  int128.plus,
+	}
+}
+
+// checkedSum is chunker that returns errOverflow instead of wrapping
+// around when the sum doesn't fit in an int64. Partial sums may overflow
+// as long as the total fits.
+func checkedSum(slice []int64, chunks int) (int64, error) {
+	x := mapReduce(slice, chunks, wide())
+	if v, ok := x.int64(); ok {
+		return v, nil
+	}
+	return 0, fmt.Errorf("%w: sum is %s", errOverflow, x.big())
+}
+
+// bigSum is chunker that promotes the sum to a big.Int when it doesn't
+// fit in an int64
+func bigSum(slice []int64, chunks int) *big.Int {
+	return mapReduce(slice, chunks, wide()).big()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"unsafe"
+)
+
+// mappedFile is a file of little-endian int64s mapped into memory
+type mappedFile struct {
+	data []byte
+	nums []int64 // data viewed as int64s
+}
+
+// openMapped maps the file at path, which must hold a whole number of
+// little-endian int64s
+func openMapped(path string) (*mappedFile, error) {
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		return nil, errors.New("mapped files need a little-endian host")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size()
+	if size%8 != 0 {
+		return nil, fmt.Errorf("%s: size %d isn't a multiple of 8 bytes", path, size)
+	}
+	if size == 0 {
+		return &mappedFile{}, nil
+	}
+	if int64(int(size)) != size {
+		return nil, fmt.Errorf("%s: too large to map", path)
+	}
+	data, err := mmap(f, int(size))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	// Mappings are page-aligned, so the view is aligned for int64
+	nums := unsafe.Slice((*int64)(unsafe.Pointer(&data[0])), len(data)/8)
+	return &mappedFile{data: data, nums: nums}, nil
+}
+
+// Close unmaps the file; the numbers mustn't be used afterwards
+func (m *mappedFile) Close() error {
+	if m.data == nil {
+		return nil
+	}
+	data := m.data
+	m.data, m.nums = nil, nil
+	return munmap(data)
+}
+
+// pagePlan gives each of workers goroutines one region of length int64s
+// that starts on a page boundary, so that no two workers fault in the same
+// page. Small inputs get fewer regions than workers.
+func pagePlan(length, workers, pageSize int) plan {
+	perPage := max(pageSize/8, 1)
+	pages := (length + perPage - 1) / perPage
+	perWorker := max((pages+workers-1)/max(workers, 1), 1) * perPage
+	var spans []span
+	for begin := 0; begin < length; begin += perWorker {
+		spans = append(spans, span{begin, min(begin+perWorker, length)})
+	}
+	if spans == nil {
+		spans = []span{{0, 0}}
+	}
+	return plan{length: length, spans: spans, workers: len(spans)}
+}
+
+// reduceMapped maps the file at path and reduces its numbers in place,
+// one page-aligned region per worker
+func reduceMapped[A any](path string, workers int, r reduction[int64, A]) (A, error) {
+	m, err := openMapped(path)
+	if err != nil {
+		var zero A
+		return zero, err
+	}
+	defer m.Close()
+	return reducePlan(m.nums, pagePlan(len(m.nums), workers, os.Getpagesize()), r), nil
+}
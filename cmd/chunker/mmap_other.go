@@ -0,0 +1,20 @@
+//go:build !unix
+
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// mmap reads the first size bytes of f into memory on systems without mmap
+func mmap(f *os.File, size int) ([]byte, error) {
+	b := make([]byte, size)
+	_, err := io.ReadFull(f, b)
+	return b, err
+}
+
+// munmap releases a mapping made by mmap
+func munmap(b []byte) error {
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// generateFile writes n generated numbers to a temporary file
+func generateFile(t testing.TB, n int, seed int64) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "numbers.bin")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	g, err := newGenerator(seed, distUniform, -998, 998)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.write(f, n); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGenerate(t *testing.T) {
+	a, err := os.ReadFile(generateFile(t, 1000, 42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(generateFile(t, 1000, 42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 8000 || !bytes.Equal(a, b) {
+		t.Fatalf("expected two identical files of 8000 bytes, got %d and %d bytes", len(a), len(b))
+	}
+}
+
+func TestReduceMapped(t *testing.T) {
+	tests := map[string]struct {
+		n       int
+		workers int
+	}{
+		"empty":  {n: 0, workers: 4},
+		"small":  {n: 10, workers: 5},
+		"medium": {n: 10045, workers: 10},
+		"large":  {n: 1000003, workers: 15},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := generateFile(t, tc.n, 1)
+			got, err := reduceMapped(path, tc.workers, sum[int64]())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Streaming the same file reads it independently of the mapping
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			cr, _ := newChunkReader(f, formatBinary)
+			want, err := reduceStream(context.Background(), cr, 1<<12, 2, withContext(sum[int64]()))
+			if err != nil || got != want {
+				t.Fatalf("expected: %v, got: %v (%v)", want, got, err)
+			}
+		})
+	}
+
+	t.Run("truncated", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "truncated.bin")
+		if err := os.WriteFile(path, make([]byte, 12), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := reduceMapped(path, 2, sum[int64]()); err == nil {
+			t.Fatal("expected an error for a partial number")
+		}
+	})
+}
+
+func TestPagePlan(t *testing.T) {
+	const pageSize = 4096
+	tests := map[string]struct {
+		length  int
+		workers int
+		regions int
+	}{
+		"empty":     {length: 0, workers: 4, regions: 1},
+		"one page":  {length: 100, workers: 4, regions: 1},
+		"few pages": {length: 3*512 + 1, workers: 8, regions: 4},
+		"even":      {length: 8 * 512 * 10, workers: 8, regions: 8},
+		"uneven":    {length: 1000003, workers: 15, regions: 15},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := pagePlan(tc.length, tc.workers, pageSize)
+			if len(p.spans) != tc.regions || p.workers != tc.regions || p.dynamic {
+				t.Fatalf("expected: %d static regions, got: %s", tc.regions, p)
+			}
+			begin := 0
+			for _, s := range p.spans {
+				if s.begin != begin || s.begin*8%pageSize != 0 {
+					t.Fatalf("unaligned or overlapping regions: %v", p.spans)
+				}
+				begin = s.end
+			}
+			if begin != tc.length {
+				t.Fatalf("regions end at %d of %d elements", begin, tc.length)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+const (
+	// chunksPerWorker is how many chunks autoPlan makes for each worker, so
+	// that workers finishing early have something left to claim
+	chunksPerWorker = 4
+	// minChunkSize is the smallest chunk autoPlan makes; below it the cost
+	// of claiming a chunk outweighs the work in it
+	minChunkSize = 1 << 13
+)
+
+// plan is how a reduction over length elements is scheduled
+type plan struct {
+	length  int
+	spans   []span
+	workers int  // goroutines doing the work
+	dynamic bool // workers claim spans as they go instead of one each
+}
+
+// staticPlan runs every one of chunks chunks in its own goroutine
+func staticPlan(length, chunks int) plan {
+	spans := split(length, chunks)
+	return plan{length: length, spans: spans, workers: len(spans)}
+}
+
+// autoPlan sizes the work to runtime.GOMAXPROCS: a pool of that many
+// workers claims chunks of at least minChunkSize elements, a few per
+// worker. Small inputs are reduced by a single worker.
+func autoPlan(length int) plan {
+	return dynamicPlan(length, runtime.GOMAXPROCS(0))
+}
+
+// dynamicPlan is autoPlan for the given number of workers
+func dynamicPlan(length, workers int) plan {
+	chunks := min(workers*chunksPerWorker, (length+minChunkSize-1)/minChunkSize)
+	spans := split(length, chunks)
+	return plan{length: length, spans: spans, workers: min(workers, len(spans)), dynamic: true}
+}
+
+// String describes the plan, such as "dynamic: 32 chunks of ~31250 elements on 8 workers"
+func (p plan) String() string {
+	kind := "static"
+	if p.dynamic {
+		kind = "dynamic"
+	}
+	return fmt.Sprintf("%s: %d chunks of ~%d elements on %d workers", kind, len(p.spans), p.length/len(p.spans), p.workers)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"cmp"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// number is any type that + works on
+type number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 |
+		~float32 | ~float64
+}
+
+// reduction describes how to reduce a slice of T to an A in parallel:
+// mapChunk reduces a single chunk and combine merges the results of two
+// adjacent chunks. combine must be associative and identity must be its
+// identity element, which is also the result for an empty slice.
+type reduction[T, A any] struct {
+	identity A
+	mapChunk func(chunk []T) A
+	combine  func(a, b A) A
+}
+
+// span is the half-open range [begin, end) of a chunk
+type span struct {
+	begin, end int
+}
+
+// split divides length elements into exactly chunks chunks whose sizes
+// differ by at most one. Fewer elements than chunks aren't split at all.
+func split(length, chunks int) []span {
+	if chunks < 1 || length < chunks {
+		return []span{{0, length}}
+	}
+	spans := make([]span, chunks)
+	size, extra := length/chunks, length%chunks
+	begin := 0
+	for i := range spans {
+		end := begin + size
+		if i < extra {
+			end++
+		}
+		spans[i] = span{begin, end}
+		begin = end
+	}
+	return spans
+}
+
+// mapReduce chunks the slice into the given number of chunks, maps every
+// chunk in its own goroutine and combines the results in chunk order, so
+// combine needn't be commutative
+func mapReduce[T, A any](slice []T, chunks int, r reduction[T, A]) A {
+	return reducePlan(slice, staticPlan(len(slice), chunks), r)
+}
+
+// autoReduce is mapReduce with the chunking chosen by autoPlan
+func autoReduce[T, A any](slice []T, r reduction[T, A]) A {
+	return reducePlan(slice, autoPlan(len(slice)), r)
+}
+
+// reducePlan runs r over the slice as scheduled by p
+func reducePlan[T, A any](slice []T, p plan, r reduction[T, A]) A {
+	results := make([]A, len(p.spans))
+	schedule(p, func(i int) {
+		s := p.spans[i]
+		results[i] = r.mapChunk(slice[s.begin:s.end])
+	})
+
+	acc := r.identity
+	for _, v := range results {
+		acc = r.combine(acc, v)
+	}
+	return acc
+}
+
+// schedule calls run with the index of every span of p on the goroutines
+// p asks for and waits for them to finish
+func schedule(p plan, run func(i int)) {
+	var wg sync.WaitGroup
+	wg.Add(p.workers)
+	if p.dynamic {
+		// Workers claim the next unclaimed chunk until there are none left,
+		// so a worker that finishes early takes over work from slower ones
+		var next int64 = -1
+		for w := 0; w < p.workers; w++ {
+			go func() {
+				defer wg.Done()
+				for i := int(atomic.AddInt64(&next, 1)); i < len(p.spans); i = int(atomic.AddInt64(&next, 1)) {
+					run(i)
+				}
+			}()
+		}
+	} else {
+		for i := range p.spans {
+			go func(i int) {
+				defer wg.Done()
+				run(i)
+			}(i)
+		}
+	}
+	wg.Wait()
+}
+
+// sum adds up the elements
+func sum[T number]() reduction[T, T] {
+	return reduction[T, T]{
+		mapChunk: func(chunk []T) T {
+			var s T
+			for _, v := range chunk {
+				s += v
+			}
+			return s
+		},
+		combine: func(a, b T) T { return a + b },
+	}
+}
+
+// optional is a value that may be missing, such as the minimum of nothing
+type optional[T any] struct {
+	value T
+	ok    bool
+}
+
+// extremum keeps the element for which better(v, best) holds
+func extremum[T any](better func(a, b T) bool) reduction[T, optional[T]] {
+	combine := func(a, b optional[T]) optional[T] {
+		if !a.ok || b.ok && better(b.value, a.value) {
+			return b
+		}
+		return a
+	}
+	return reduction[T, optional[T]]{
+		mapChunk: func(chunk []T) optional[T] {
+			var best optional[T]
+			for _, v := range chunk {
+				best = combine(best, optional[T]{v, true})
+			}
+			return best
+		},
+		combine: combine,
+	}
+}
+
+// minimum finds the smallest element
+func minimum[T cmp.Ordered]() reduction[T, optional[T]] {
+	return extremum(cmp.Less[T])
+}
+
+// maximum finds the largest element
+func maximum[T cmp.Ordered]() reduction[T, optional[T]] {
+	return extremum(func(a, b T) bool { return cmp.Less(b, a) })
+}
+
+// histogram counts the elements falling in each bucket, as numbered by bucket
+func histogram[T any](bucket func(T) int) reduction[T, map[int]int] {
+	return reduction[T, map[int]int]{
+		mapChunk: func(chunk []T) map[int]int {
+			h := map[int]int{}
+			for _, v := range chunk {
+				h[bucket(v)]++
+			}
+			return h
+		},
+		// The chunk histograms are merged into a new map, leaving identity
+		// and the partial results untouched
+		combine: func(a, b map[int]int) map[int]int {
+			h := make(map[int]int, len(a)+len(b))
+			for k, n := range a {
+				h[k] += n
+			}
+			for k, n := range b {
+				h[k] += n
+			}
+			return h
+		},
+	}
+}
+
+// kahan is a float64 sum together with the rounding error it has lost
+type kahan struct {
+	sum, c float64
+}
+
+// add adds v to k with Neumaier's variant of Kahan summation, which also
+// compensates when v is larger than the running sum
+func (k kahan) add(v float64) kahan {
+	t := k.sum + v
+	if math.Abs(k.sum) >= math.Abs(v) {
+		k.c += (k.sum - t) + v
+	} else {
+		k.c += (v - t) + k.sum
+	}
+	k.sum = t
+	return k
+}
+
+// value is the compensated sum
+func (k kahan) value() float64 {
+	return k.sum + k.c
+}
+
+// kahanSum adds up float64s with compensated summation, which keeps the
+// error independent of the number of elements
+func kahanSum() reduction[float64, kahan] {
+	return reduction[float64, kahan]{
+		mapChunk: func(chunk []float64) kahan {
+			var k kahan
+			for _, v := range chunk {
+				k = k.add(v)
+			}
+			return k
+		},
+		combine: func(a, b kahan) kahan {
+			a = a.add(b.sum)
+			a.c += b.c
+			return a
+		},
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Formats of the scaling report
+const (
+	reportTable = "table"
+	reportCSV   = "csv"
+)
+
+// autoChunks stands for "auto" in a list of chunk counts: the reduction
+// runs with autoReduce, which picks the chunks and workers itself
+const autoChunks = -1
+
+// job runs one kind of reduction over a fixed input, sequentially on the
+// calling goroutine when chunks is 0, with autoReduce when it is autoChunks
+// and with mapReduce otherwise, and formats the result
+type job func(chunks int) string
+
+// jobs builds each reduction selectable with -reduce over a slice
+var jobs = map[string]func(slice []int64) job{
+	"sum": func(slice []int64) job {
+		return reductionJob(slice, sum[int64](), formatInt)
+	},
+	"unrolled": func(slice []int64) job {
+		return reductionJob(slice, unrolled(), formatInt)
+	},
+	"wide": func(slice []int64) job {
+		return reductionJob(slice, wide(), func(x int128) string { return x.big().String() })
+	},
+	"min": func(slice []int64) job {
+		return reductionJob(slice, minimum[int64](), formatOptional)
+	},
+	"max": func(slice []int64) job {
+		return reductionJob(slice, maximum[int64](), formatOptional)
+	},
+	"kahan": func(slice []int64) job {
+		floats := make([]float64, len(slice))
+		for i, v := range slice {
+			floats[i] = float64(v)
+		}
+		return reductionJob(floats, kahanSum(), func(k kahan) string {
+			return strconv.FormatFloat(k.value(), 'g', -1, 64)
+		})
+	},
+}
+
+// jobNames lists the keys of jobs in order
+func jobNames() []string {
+	names := make([]string, 0, len(jobs))
+	for name := range jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// reductionJob runs r over slice
+func reductionJob[T, A any](slice []T, r reduction[T, A], format func(A) string) job {
+	return func(chunks int) string {
+		switch chunks {
+		case 0:
+			return format(r.combine(r.identity, r.mapChunk(slice)))
+		case autoChunks:
+			return format(autoReduce(slice, r))
+		}
+		return format(mapReduce(slice, chunks, r))
+	}
+}
+
+func formatInt(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+func formatOptional(v optional[int64]) string {
+	if !v.ok {
+		return "none"
+	}
+	return formatInt(v.value)
+}
+
+// parseChunks parses a comma-separated list of positive chunk counts, in
+// which "auto" is autoChunks
+func parseChunks(s string) ([]int, error) {
+	var counts []int
+	for _, f := range strings.Split(s, ",") {
+		if strings.TrimSpace(f) == "auto" {
+			counts = append(counts, autoChunks)
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid chunk count %q", f)
+		}
+		counts = append(counts, n)
+	}
+	return counts, nil
+}
+
+// measure runs j repeat times with chunks chunks and returns the fastest
+// run, which is the least disturbed by the rest of the machine
+func measure(j job, chunks, repeat int) time.Duration {
+	var best time.Duration
+	for i := 0; i < max(repeat, 1); i++ {
+		start := time.Now()
+		j(chunks)
+		if d := time.Since(start); i == 0 || d < best {
+			best = d
+		}
+	}
+	return best
+}
+
+// row is one line of the scaling report
+type row struct {
+	config     string
+	goroutines int
+	elapsed    time.Duration
+	throughput float64 // elements per second
+	speedup    float64 // over the sequential run
+	efficiency float64 // speedup per goroutine that can run in parallel
+}
+
+// scaling measures j sequentially, then with each of the chunk counts over
+// length elements and with autoReduce, last unless counts lists it
+func scaling(j job, length int, counts []int, repeat int) []row {
+	procs := runtime.GOMAXPROCS(0)
+	base := measure(j, 0, repeat)
+	rows := []row{newRow("sequential", 1, 1, length, base, base)}
+	if !slices.Contains(counts, autoChunks) {
+		counts = append(counts[:len(counts):len(counts)], autoChunks)
+	}
+	for _, c := range counts {
+		config, goroutines := strconv.Itoa(c), len(split(length, c))
+		if c == autoChunks {
+			config, goroutines = "auto", autoPlan(length).workers
+		}
+		parallel := min(goroutines, procs)
+		rows = append(rows, newRow(config, goroutines, parallel, length, measure(j, c, repeat), base))
+	}
+	return rows
+}
+
+func newRow(config string, goroutines, parallel, length int, elapsed, base time.Duration) row {
+	// Clamp to a nanosecond so that trivial inputs don't divide by zero
+	seconds := float64(max(elapsed, time.Nanosecond)) / float64(time.Second)
+	speedup := float64(max(base, time.Nanosecond)) / float64(max(elapsed, time.Nanosecond))
+	return row{
+		config:     config,
+		goroutines: goroutines,
+		elapsed:    elapsed,
+		throughput: float64(length) / seconds,
+		speedup:    speedup,
+		efficiency: speedup / float64(parallel),
+	}
+}
+
+// writeReport writes rows as an aligned table or as CSV
+func writeReport(w io.Writer, format string, rows []row) error {
+	header := []string{"chunks", "goroutines", "time", "elements/s", "speedup", "efficiency"}
+	records := make([][]string, len(rows))
+	for i, r := range rows {
+		records[i] = []string{
+			r.config,
+			strconv.Itoa(r.goroutines),
+			r.elapsed.String(),
+			strconv.FormatFloat(r.throughput, 'e', 3, 64),
+			strconv.FormatFloat(r.speedup, 'f', 2, 64),
+			strconv.FormatFloat(r.efficiency, 'f', 2, 64),
+		}
+	}
+
+	if format == reportCSV {
+		cw := csv.NewWriter(w)
+		cw.Write(header)
+		cw.WriteAll(records)
+		return cw.Error()
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for _, r := range append([][]string{header}, records...) {
+		fmt.Fprintln(tw, strings.Join(r, "\t")+"\t")
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestJobs(t *testing.T) {
+	input := sliceGenerator(100003)
+	for _, name := range jobNames() {
+		t.Run(name, func(t *testing.T) {
+			j := jobs[name](input)
+			want := j(0)
+			for _, chunks := range []int{1, 7, 16, autoChunks} {
+				if got := j(chunks); got != want {
+					t.Fatalf("%d chunks: expected: %v, got: %v", chunks, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseChunks(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  []int
+	}{
+		"one":      {input: "10", want: []int{10}},
+		"auto":     {input: "auto, 4", want: []int{autoChunks, 4}},
+		"list":     {input: "1, 2,4,8", want: []int{1, 2, 4, 8}},
+		"zero":     {input: "1,0", want: nil},
+		"empty":    {input: "", want: nil},
+		"trailing": {input: "1,2,", want: nil},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseChunks(tc.input)
+			if !reflect.DeepEqual(tc.want, got) || (err == nil) != (tc.want != nil) {
+				t.Fatalf("expected: %v, got: %v (%v)", tc.want, got, err)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	rows := scaling(jobs["sum"](sliceGenerator(10045)), 10045, []int{1, 3, 20000}, 2)
+	var buf bytes.Buffer
+	if err := writeReport(&buf, reportCSV, rows); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 6 {
+		t.Fatalf("expected: a header and 5 rows, got: %v", records)
+	}
+	// More chunks than elements aren't split
+	auto := fmt.Sprintf("auto,%d", autoPlan(10045).workers)
+	for i, want := range []string{"sequential,1", "1,1", "3,3", "20000,1", auto} {
+		if got := records[i+1][0] + "," + records[i+1][1]; got != want {
+			t.Fatalf("row %d: expected: %v, got: %v", i+1, want, got)
+		}
+	}
+	if rows[0].speedup != 1 || rows[0].efficiency != 1 {
+		t.Fatalf("expected the sequential run to be the baseline, got: %+v", rows[0])
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+// inclusiveScan writes the prefix sums of slice to out, which must be as
+// long: out[i] = slice[0] + ... + slice[i]. out may be slice itself.
+func inclusiveScan[T number](out, slice []T, chunks int) {
+	scan(out, slice, chunks, true)
+}
+
+// exclusiveScan is inclusiveScan without each element's own value:
+// out[i] = slice[0] + ... + slice[i-1], and out[0] = 0
+func exclusiveScan[T number](out, slice []T, chunks int) {
+	scan(out, slice, chunks, false)
+}
+
+// scan computes prefix sums in two parallel passes over the chunks
+// mapReduce uses: the first sums every chunk, the sums of the chunks before
+// each one give its offset, and the second scans every chunk starting from
+// its offset
+func scan[T number](out, slice []T, chunks int, inclusive bool) {
+	if len(out) != len(slice) {
+		panic("chunker: scan output and input lengths differ")
+	}
+	p := staticPlan(len(slice), chunks)
+	totals := make([]T, len(p.spans))
+	kernel := sum[T]().mapChunk
+	schedule(p, func(i int) {
+		s := p.spans[i]
+		totals[i] = kernel(slice[s.begin:s.end])
+	})
+
+	// The few chunk totals are scanned sequentially
+	var offset T
+	for i, t := range totals {
+		totals[i], offset = offset, offset+t
+	}
+
+	schedule(p, func(i int) {
+		acc := totals[i]
+		s := p.spans[i]
+		for j := s.begin; j < s.end; j++ {
+			// Read before writing, so that out can be slice
+			v := slice[j]
+			if inclusive {
+				acc += v
+				out[j] = acc
+			} else {
+				out[j] = acc
+				acc += v
+			}
+		}
+	})
+}
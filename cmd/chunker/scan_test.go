@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// plainScan computes the prefix sums sequentially
+func plainScan(slice []int64, inclusive bool) []int64 {
+	out := make([]int64, len(slice))
+	var acc int64
+	for i, v := range slice {
+		if inclusive {
+			acc += v
+			out[i] = acc
+		} else {
+			out[i] = acc
+			acc += v
+		}
+	}
+	return out
+}
+
+func TestScan(t *testing.T) {
+	tests := map[string]struct {
+		input  []int64
+		chunks int
+	}{
+		"empty":  {input: nil, chunks: 3},
+		"small":  {input: sliceGenerator(10), chunks: 5},
+		"medium": {input: sliceGenerator(1004), chunks: 10},
+		"large":  {input: sliceGenerator(1000001), chunks: 15},
+	}
+	scans := map[string]struct {
+		scan      func(out, slice []int64, chunks int)
+		inclusive bool
+	}{
+		"inclusive": {scan: inclusiveScan[int64], inclusive: true},
+		"exclusive": {scan: exclusiveScan[int64], inclusive: false},
+	}
+
+	for name, tc := range tests {
+		for kind, sc := range scans {
+			t.Run(fmt.Sprintf("%s %s", name, kind), func(t *testing.T) {
+				want := plainScan(tc.input, sc.inclusive)
+				got := make([]int64, len(tc.input))
+				sc.scan(got, tc.input, tc.chunks)
+				if !reflect.DeepEqual(want, got) {
+					t.Fatalf("expected: %v, got: %v", want, got)
+				}
+
+				// In place
+				copy(got, tc.input)
+				sc.scan(got, got, tc.chunks)
+				if !reflect.DeepEqual(want, got) {
+					t.Fatalf("in place: expected: %v, got: %v", want, got)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkScan(b *testing.B) {
+	benchmarks := map[string]struct {
+		input  []int64
+		chunks int
+	}{
+		"small":  {input: sliceGenerator(10), chunks: 5},
+		"medium": {input: sliceGenerator(10045), chunks: 10},
+		"large":  {input: sliceGenerator(10000010), chunks: 15},
+	}
+	for name, bm := range benchmarks {
+		out := make([]int64, len(bm.input))
+		b.Run(fmt.Sprintf("BenchmarkInclusiveScan: %s", name), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				inclusiveScan(out, bm.input, bm.chunks)
+			}
+		})
+		b.Run(fmt.Sprintf("BenchmarkPlainScan: %s", name), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = plainScan(bm.input, true)
+			}
+		})
+	}
+}
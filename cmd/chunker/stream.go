@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Encodings of the numbers reduceStream reads
+const (
+	formatText   = "text"   // one decimal int64 per line, blank lines ignored
+	formatBinary = "binary" // little-endian int64s, 8 bytes each
+)
+
+// chunkReader fills buf with the next numbers of a stream, returning how
+// many it read and io.EOF once the stream is exhausted
+type chunkReader interface {
+	readChunk(buf []int64) (int, error)
+}
+
+// newChunkReader reads numbers from r in one of the stream formats
+func newChunkReader(r io.Reader, format string) (chunkReader, error) {
+	switch format {
+	case formatText:
+		return &textReader{scanner: bufio.NewScanner(r)}, nil
+	case formatBinary:
+		return &binaryReader{r: bufio.NewReader(r)}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// textReader reads one number per line
+type textReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (t *textReader) readChunk(buf []int64) (int, error) {
+	n := 0
+	for n < len(buf) && t.scanner.Scan() {
+		t.line++
+		text := strings.TrimSpace(t.scanner.Text())
+		if text == "" {
+			continue
+		}
+		v, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return n, fmt.Errorf("line %d: %w", t.line, err)
+		}
+		buf[n] = v
+		n++
+	}
+	if n < len(buf) {
+		if err := t.scanner.Err(); err != nil {
+			return n, err
+		}
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// binaryReader reads little-endian int64s
+type binaryReader struct {
+	r     *bufio.Reader
+	bytes []byte
+	read  int64 // numbers read so far
+}
+
+func (b *binaryReader) readChunk(buf []int64) (int, error) {
+	if len(b.bytes) < 8*len(buf) {
+		b.bytes = make([]byte, 8*len(buf))
+	}
+	got, err := io.ReadFull(b.r, b.bytes[:8*len(buf)])
+	n := got / 8
+	for i := range buf[:n] {
+		buf[i] = int64(binary.LittleEndian.Uint64(b.bytes[8*i:]))
+	}
+	b.read += int64(n)
+	switch {
+	case got%8 != 0:
+		return n, fmt.Errorf("number %d: truncated after %d of 8 bytes", b.read+1, got%8)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return n, io.EOF
+	}
+	return n, err
+}
+
+// reduceStream reduces the numbers read from cr chunkSize at a time. Each
+// chunk is handed to one of workers goroutines as soon as it is read, and
+// at most 2*workers chunks are held at once, so memory doesn't depend on
+// the length of the stream. Chunks are combined in stream order. Errors
+// and cancellation behave as in reducePlanContext, except that a read
+// that blocks delays the return until it completes.
+func reduceStream[A any](ctx context.Context, cr chunkReader, chunkSize, workers int, r contextReduction[int64, A]) (A, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	chunkSize, workers = max(chunkSize, 1), max(workers, 1)
+
+	var (
+		once     sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	type job struct {
+		i   int
+		buf []int64
+	}
+	type result struct {
+		job
+		v   A
+		err error
+	}
+	// A buffer goes back to free only once its chunk has been combined,
+	// which bounds the chunks being read, reduced or waiting for their turn
+	free := make(chan []int64, 2*workers)
+	for i := 0; i < cap(free); i++ {
+		free <- make([]int64, chunkSize)
+	}
+	jobs := make(chan job)
+	results := make(chan result, cap(free))
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				v, err := r.mapChunk(ctx, j.buf)
+				results <- result{j, v, err}
+			}
+		}()
+	}
+
+	acc := r.identity
+	merged := make(chan struct{})
+	go func() {
+		defer close(merged)
+		pending := map[int]result{}
+		next := 0
+		for res := range results {
+			if res.err != nil {
+				fail(res.err)
+				free <- res.buf[:cap(res.buf)]
+				continue
+			}
+			pending[res.i] = res
+			for p, ok := pending[next]; ok; p, ok = pending[next] {
+				acc = r.combine(acc, p.v)
+				free <- p.buf[:cap(p.buf)]
+				delete(pending, next)
+				next++
+			}
+		}
+	}()
+
+read:
+	for i := 0; ; i++ {
+		var buf []int64
+		select {
+		case buf = <-free:
+		case <-ctx.Done():
+			fail(ctx.Err())
+			break read
+		}
+		n, err := cr.readChunk(buf)
+		if n > 0 {
+			jobs <- job{i, buf[:n]}
+		} else {
+			free <- buf
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fail(err)
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(results)
+	<-merged
+
+	if firstErr != nil {
+		var zero A
+		return zero, firstErr
+	}
+	return acc, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// encode writes slice in one of the stream formats
+func encode(slice []int64, format string) []byte {
+	var buf bytes.Buffer
+	for _, v := range slice {
+		if format == formatBinary {
+			binary.Write(&buf, binary.LittleEndian, v)
+		} else {
+			fmt.Fprintln(&buf, v)
+		}
+	}
+	return buf.Bytes()
+}
+
+// concat collects the elements in the order they are combined
+func concat() contextReduction[int64, []int64] {
+	return contextReduction[int64, []int64]{
+		mapChunk: func(ctx context.Context, chunk []int64) ([]int64, error) {
+			return append([]int64(nil), chunk...), nil
+		},
+		combine: func(a, b []int64) []int64 { return append(a, b...) },
+	}
+}
+
+// zeros is an endless stream of zeros
+type zeros struct{}
+
+func (zeros) readChunk(buf []int64) (int, error) {
+	for i := range buf {
+		buf[i] = 0
+	}
+	return len(buf), nil
+}
+
+func TestReduceStream(t *testing.T) {
+	tests := map[string]struct {
+		input     []int64
+		chunkSize int
+		workers   int
+	}{
+		"empty":  {input: nil, chunkSize: 4, workers: 2},
+		"small":  {input: sliceGenerator(10), chunkSize: 3, workers: 2},
+		"medium": {input: sliceGenerator(1004), chunkSize: 100, workers: 4},
+		"large":  {input: sliceGenerator(1000001), chunkSize: 1 << 12, workers: 8},
+	}
+
+	for name, tc := range tests {
+		for _, format := range []string{formatText, formatBinary} {
+			t.Run(fmt.Sprintf("%s %s", name, format), func(t *testing.T) {
+				data := encode(tc.input, format)
+				cr, err := newChunkReader(bytes.NewReader(data), format)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := reduceStream(context.Background(), cr, tc.chunkSize, tc.workers, withContext(sum[int64]()))
+				if s := plainSum(tc.input); err != nil || got != s {
+					t.Fatalf("expected: %v, got: %v (%v)", s, got, err)
+				}
+
+				// Chunks reduced out of order are still combined in order
+				cr, _ = newChunkReader(bytes.NewReader(data), format)
+				all, err := reduceStream(context.Background(), cr, tc.chunkSize, tc.workers, concat())
+				if err != nil || !reflect.DeepEqual(all, tc.input) {
+					t.Fatalf("elements out of order (%v)", err)
+				}
+			})
+		}
+	}
+}
+
+func TestReduceStreamErrors(t *testing.T) {
+	tests := map[string]struct {
+		data   string
+		format string
+		err    string
+	}{
+		"bad line":  {data: "1\n2\n\nthree\n4\n", format: formatText, err: "line 4: "},
+		"overflow":  {data: "9223372036854775808\n", format: formatText, err: "line 1: "},
+		"truncated": {data: string(encode([]int64{1, 2}, formatBinary)[:13]), format: formatBinary, err: "number 2: truncated after 5 of 8 bytes"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cr, err := newChunkReader(strings.NewReader(tc.data), tc.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = reduceStream(context.Background(), cr, 2, 2, withContext(sum[int64]()))
+			if err == nil || !strings.HasPrefix(err.Error(), tc.err) {
+				t.Fatalf("expected: %s..., got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestReduceStreamCancel(t *testing.T) {
+	// An endless stream can only be reduced in bounded memory
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := reduceWithin(t, func() (int64, error) {
+		return reduceStream(ctx, zeros{}, 1<<10, 4, withContext(sum[int64]()))
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected: %v, got: %v", context.DeadlineExceeded, err)
+	}
+	checkLeaks(t, before)
+}
@@ -1,75 +1,210 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"math/rand"
-	"time"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+)
+
+const elements = 100000000
+
+var (
+	chunkList string
+	repeat    int
+	reduce    string
+	report    string
 )
 
 const (
-	elements = 100000000
-	chunks   = 10
+	usageChunks = "<string>: comma-separated chunk counts, or auto to let the workers claim chunks sized to GOMAXPROCS; the reduction runs with the first, or with each in a -report"
+	usageRepeat = "<int>: runs of each configuration in a -report, of which the fastest is reported"
+	usageReduce = "<string>: reduction over the generated numbers: kahan, max, min, sum, unrolled or wide (exact sum)"
+	usageReport = "<string>: instead of printing the result, time the reduction sequentially and with each -chunks count and print the scaling as a table or csv"
+
+	defaultChunks = "10"
+	defaultRepeat = 3
+	defaultReduce = "sum"
+	defaultReport = ""
+)
+
+var (
+	input     string
+	format    string
+	chunkSize int
+	mapped    bool
+
+	// Flags of the generated input, shared with the generate subcommand
+	size   int
+	seed   int64
+	dist   string
+	lo, hi int64
 )
 
-// chunkSum sums up the chunked slice
-func chunkSum(s []int64, c chan<- int64) {
-	var sum int64
-	for _, v := range s {
-		sum += v
+const (
+	usageInput     = "<string>: sum the numbers read from this file (- for STDIN) instead of a generated slice"
+	usageFormat    = "<string>: encoding of -input: text (one number per line) or binary (little-endian int64)"
+	usageChunkSize = "<int>: numbers read from -input per chunk; memory is bounded by 2*GOMAXPROCS chunks"
+	usageMmap      = "<bool>: map the -input file, of little-endian int64s, into memory and sum it in place instead of streaming it"
+
+	defaultInput     = ""
+	defaultFormat    = formatText
+	defaultChunkSize = 1 << 16
+	defaultMmap      = false
+)
+
+const (
+	usageSize = "<int>: number of int64s to generate"
+	usageSeed = "<int>: seed of the generated numbers; the same flags always generate the same numbers"
+	usageDist = "<string>: distribution of the generated numbers: uniform, normal, zipf, equal (all -max) or alternating (signs in turn)"
+	usageMin  = "<int>: smallest generated number"
+	usageMax  = "<int>: largest generated number"
+
+	defaultSize = elements
+	defaultSeed = 1
+	defaultDist = distUniform
+	defaultMin  = -998
+	defaultMax  = 998
+)
+
+// generatorFlags defines the flags of the generated input on fs
+func generatorFlags(fs *flag.FlagSet) {
+	fs.IntVar(&size, "n", defaultSize, usageSize)
+	fs.Int64Var(&seed, "seed", defaultSeed, usageSeed)
+	fs.StringVar(&dist, "dist", defaultDist, usageDist)
+	fs.Int64Var(&lo, "min", defaultMin, usageMin)
+	fs.Int64Var(&hi, "max", defaultMax, usageMax)
+}
+
+// flagGenerator is the generator the flags describe, exiting on invalid ones
+func flagGenerator() generator {
+	g, err := newGenerator(seed, dist, lo, hi)
+	if err != nil || size < 0 {
+		if err == nil {
+			err = fmt.Errorf("negative size %d", size)
+		}
+		fmt.Fprintf(os.Stderr, "chunker: %v. Try (-h) or (--help) flag\n", err)
+		os.Exit(2)
 	}
-	c <- sum
+	return g
 }
 
 // chunker chunks the slice and spawns goroutines
-// for each of the chunk to processed by chunkSum
+// for each of the chunk to be summed up
 func chunker(slice []int64, chunks int) int64 {
-	length := len(slice)
-	collector := make(chan int64, chunks)
-	// If the length of slice if lesser than chunks, then don't chunk
-	if length < chunks {
-		go chunkSum(slice, collector)
-		return <-collector
+	return mapReduce(slice, chunks, sum[int64]())
+}
+
+// sumInput sums the numbers of -input as they are read, or in place if
+// -mmap is set
+func sumInput() (int64, error) {
+	if mapped {
+		return reduceMapped(input, runtime.GOMAXPROCS(0), sum[int64]())
 	}
+	in := os.Stdin
+	if input != "-" {
+		f, err := os.Open(input)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		in = f
+	}
+	cr, err := newChunkReader(in, format)
+	if err != nil {
+		return 0, err
+	}
+	return reduceStream(context.Background(), cr, chunkSize, runtime.GOMAXPROCS(0), withContext(sum[int64]()))
+}
 
-	// Chunk and add until end doesn't reach boundary
-	buckets := length / chunks
-	begin := 0
-	end := buckets
-	for end <= length {
-		go chunkSum(slice[begin:end], collector)
-		begin = end
-		end += buckets
+// generateCmd writes the file named by the last of args, which are the
+// arguments of the generate subcommand
+func generateCmd(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	generatorFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chunker generate [flags] file")
+		fs.PrintDefaults()
 	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	g := flagGenerator()
 
-	// If some elements in the slice are still left
-	routine := 0
-	if length%chunks != 0 {
-		routine++
-		go chunkSum(slice[begin:], collector)
+	f, err := os.Create(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := g.write(f, size); err != nil {
+		f.Close()
+		return err
 	}
+	return f.Close()
+}
 
-	// Receive from every goroutine
-	var sum int64
-	for i := 0; i < chunks+routine; i++ {
-		sum += <-collector
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "generate" {
+		if err := generateCmd(os.Args[2:]); err != nil {
+			log.Fatalln(err)
+		}
+		return
 	}
 
-	// Close the channel
-	close(collector)
-	return sum
-}
+	flag.StringVar(&input, "input", defaultInput, usageInput)
+	flag.StringVar(&format, "format", defaultFormat, usageFormat)
+	flag.IntVar(&chunkSize, "chunk-size", defaultChunkSize, usageChunkSize)
+	flag.BoolVar(&mapped, "mmap", defaultMmap, usageMmap)
+	flag.StringVar(&chunkList, "chunks", defaultChunks, usageChunks)
+	flag.IntVar(&repeat, "repeat", defaultRepeat, usageRepeat)
+	flag.StringVar(&reduce, "reduce", defaultReduce, usageReduce)
+	flag.StringVar(&report, "report", defaultReport, usageReport)
+	generatorFlags(flag.CommandLine)
+	flag.Parse()
 
-// sliceGenerator generates a slice of size: size
-func sliceGenerator(size int) []int64 {
-	slice := make([]int64, size)
-	rand.Seed(time.Now().UnixNano())
-	for i := 0; i < size; i++ {
-		slice[i] = rand.Int63n(999) - rand.Int63n(999)
+	if mapped && (input == "" || input == "-") {
+		log.Fatalln("-mmap needs an -input file")
+	}
+	if input != "" && (reduce != defaultReduce || report != "") {
+		log.Fatalln("-input is always summed and can't be combined with -reduce or -report")
 	}
-	return slice
-}
 
-func main() {
-	stream := sliceGenerator(elements)
-	fmt.Printf("Result: %d\n", chunker(stream, chunks))
+	if input != "" {
+		total, err := sumInput()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Printf("Result: %d\n", total)
+		return
+	}
+
+	counts, err := parseChunks(chunkList)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "chunker: %v. Try (-h) or (--help) flag\n", err)
+		os.Exit(2)
+	}
+	newJob, ok := jobs[reduce]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "chunker: unknown reduction %q, expected one of %s. Try (-h) or (--help) flag\n", reduce, strings.Join(jobNames(), ", "))
+		os.Exit(2)
+	}
+	switch report {
+	case "", reportTable, reportCSV:
+	default:
+		fmt.Fprintf(os.Stderr, "chunker: unknown report format %q. Try (-h) or (--help) flag\n", report)
+		os.Exit(2)
+	}
+
+	j := newJob(flagGenerator().slice(size))
+	if report == "" {
+		fmt.Printf("Result: %s\n", j(counts[0]))
+		return
+	}
+	if err := writeReport(os.Stdout, report, scaling(j, size, counts, repeat)); err != nil {
+		log.Fatalln(err)
+	}
 }
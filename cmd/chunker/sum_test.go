@@ -1,11 +1,50 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"maps"
+	"math"
+	"math/big"
+	"os"
 	"reflect"
+	"runtime"
 	"testing"
+	"time"
 )
 
+// testSeed seeds every generated input; a failing run is replayed by passing
+// the seed it logged
+var testSeed = flag.Int64("seed", 0, "<int>: seed of the generated inputs, 0 for a new one")
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if *testSeed == 0 {
+		*testSeed = time.Now().UnixNano()
+	}
+	// The seed is printed up front with -v, and otherwise only on failure
+	if testing.Verbose() {
+		fmt.Printf("seed: %d (replay with -seed %[1]d)\n", *testSeed)
+	}
+	code := m.Run()
+	if code != 0 && !testing.Verbose() {
+		fmt.Printf("seed: %d (replay with -seed %[1]d)\n", *testSeed)
+	}
+	os.Exit(code)
+}
+
+// sliceGenerator generates a slice of size: size. The values depend only on
+// the seed and the size, so every test gets the same input when replayed
+// alone.
+func sliceGenerator(size int) []int64 {
+	g, err := newGenerator(*testSeed+int64(size), distUniform, defaultMin, defaultMax)
+	if err != nil {
+		panic(err)
+	}
+	return g.slice(size)
+}
+
 func plainSum(slice []int64) int64 {
 	var sum int64 = 0
 	for _, num := range slice {
@@ -30,6 +69,12 @@ func BenchmarkAdders(b *testing.B) {
 				_ = chunker(bm.input, bm.chunks)
 			}
 		})
+		b.Run(fmt.Sprintf("BenchmarkDynamic: %s", name), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = autoReduce(bm.input, sum[int64]())
+			}
+		})
 		b.Run(fmt.Sprintf("BenchmarkPlainSum: %s", name), func(b *testing.B) {
 			b.ReportAllocs()
 			for i := 0; i < b.N; i++ {
@@ -39,6 +84,46 @@ func BenchmarkAdders(b *testing.B) {
 	}
 }
 
+func BenchmarkKernels(b *testing.B) {
+	benchmarks := map[string]struct {
+		input []int64
+	}{
+		"small":  {input: sliceGenerator(10)},
+		"medium": {input: sliceGenerator(10045)},
+		"large":  {input: sliceGenerator(10000010)},
+	}
+	kernels := map[string]func([]int64){
+		"PlainSum":    func(s []int64) { _ = plainSum(s) },
+		"UnrolledSum": func(s []int64) { _ = unrolledSum(s) },
+		"WideSum":     func(s []int64) { _ = wideSum(s) },
+	}
+	for name, bm := range benchmarks {
+		for kernel, f := range kernels {
+			b.Run(fmt.Sprintf("Benchmark%s: %s", kernel, name), func(b *testing.B) {
+				b.ReportAllocs()
+				b.SetBytes(int64(8 * len(bm.input)))
+				for i := 0; i < b.N; i++ {
+					f(bm.input)
+				}
+			})
+		}
+		b.Run(fmt.Sprintf("BenchmarkCheckedSum: %s", name), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(8 * len(bm.input)))
+			for i := 0; i < b.N; i++ {
+				_, _ = checkedSum(bm.input, 10)
+			}
+		})
+		b.Run(fmt.Sprintf("BenchmarkBigSum: %s", name), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(8 * len(bm.input)))
+			for i := 0; i < b.N; i++ {
+				_ = bigSum(bm.input, 10)
+			}
+		})
+	}
+}
+
 func TestChunker(t *testing.T) {
 	tests := map[string]struct {
 		input  []int64
@@ -55,6 +140,197 @@ func TestChunker(t *testing.T) {
 			if s := plainSum(tc.input); !reflect.DeepEqual(s, got) {
 				t.Fatalf("expected: %v, got: %v", s, got)
 			}
+			if got, s := autoReduce(tc.input, sum[int64]()), plainSum(tc.input); got != s {
+				t.Fatalf("autoReduce: expected: %v, got: %v", s, got)
+			}
+		})
+	}
+}
+
+func TestReductions(t *testing.T) {
+	tests := map[string]struct {
+		input  []int64
+		chunks int
+	}{
+		"empty":  {input: nil, chunks: 3},
+		"small":  {input: sliceGenerator(10), chunks: 5},
+		"medium": {input: sliceGenerator(1004), chunks: 10},
+		"large":  {input: sliceGenerator(1000001), chunks: 15},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Sequential results to compare against
+			var (
+				lo, hi optional[int64]
+				hist   = map[int]int{}
+				floats = make([]float64, len(tc.input))
+			)
+			for i, v := range tc.input {
+				if !lo.ok || v < lo.value {
+					lo = optional[int64]{v, true}
+				}
+				if !hi.ok || v > hi.value {
+					hi = optional[int64]{v, true}
+				}
+				hist[int(v/100)]++
+				floats[i] = float64(v)
+			}
+
+			if got := mapReduce(tc.input, tc.chunks, sum[int64]()); got != plainSum(tc.input) {
+				t.Fatalf("sum: expected: %v, got: %v", plainSum(tc.input), got)
+			}
+			if got := mapReduce(tc.input, tc.chunks, minimum[int64]()); got != lo {
+				t.Fatalf("minimum: expected: %v, got: %v", lo, got)
+			}
+			if got := mapReduce(tc.input, tc.chunks, maximum[int64]()); got != hi {
+				t.Fatalf("maximum: expected: %v, got: %v", hi, got)
+			}
+			got := mapReduce(tc.input, tc.chunks, histogram(func(v int64) int { return int(v / 100) }))
+			if !maps.Equal(hist, got) {
+				t.Fatalf("histogram: expected: %v, got: %v", hist, got)
+			}
+			// The elements are small integers, so their float64 sum is exact
+			if got := mapReduce(floats, tc.chunks, kahanSum()).value(); got != float64(plainSum(tc.input)) {
+				t.Fatalf("kahanSum: expected: %v, got: %v", float64(plainSum(tc.input)), got)
+			}
+		})
+	}
+}
+
+func TestKahanSum(t *testing.T) {
+	// 1 followed by many values too small to change it one at a time
+	input := make([]float64, 1000001)
+	input[0] = 1
+	for i := 1; i < len(input); i++ {
+		input[i] = 1e-16
+	}
+	want := 1 + 1e-10
+	for _, chunks := range []int{1, 7, 16} {
+		if got := mapReduce(input, chunks, kahanSum()).value(); math.Abs(got-want) > 1e-15 {
+			t.Fatalf("%d chunks: expected: %v, got: %v", chunks, want, got)
+		}
+	}
+}
+
+// BenchmarkSchedulers compares one goroutine per chunk with a pool of
+// GOMAXPROCS workers claiming chunks, on the same number of chunks
+func BenchmarkSchedulers(b *testing.B) {
+	input := sliceGenerator(10000010)
+	workers := runtime.GOMAXPROCS(0)
+	plans := map[string]plan{
+		"static":  staticPlan(len(input), workers*chunksPerWorker),
+		"dynamic": dynamicPlan(len(input), workers),
+	}
+	for name, p := range plans {
+		b.Run(fmt.Sprintf("BenchmarkPlan: %s", name), func(b *testing.B) {
+			b.Logf("%s", p)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = reducePlan(input, p, sum[int64]())
+			}
+		})
+	}
+}
+
+func TestPlans(t *testing.T) {
+	tests := map[string]struct {
+		plan    plan
+		chunks  int
+		workers int
+	}{
+		"static even":      {plan: staticPlan(100, 10), chunks: 10, workers: 10},
+		"static remainder": {plan: staticPlan(1004, 10), chunks: 10, workers: 10},
+		"static short":     {plan: staticPlan(3, 5), chunks: 1, workers: 1},
+		"dynamic empty":    {plan: dynamicPlan(0, 8), chunks: 1, workers: 1},
+		"dynamic small":    {plan: dynamicPlan(minChunkSize*3-1, 8), chunks: 3, workers: 3},
+		"dynamic large":    {plan: dynamicPlan(10000010, 8), chunks: 8 * chunksPerWorker, workers: 8},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := tc.plan
+			if len(p.spans) != tc.chunks || p.workers != tc.workers {
+				t.Fatalf("expected: %d chunks on %d workers, got: %s", tc.chunks, tc.workers, p)
+			}
+			// The spans tile the input and differ in size by at most one
+			begin := 0
+			for _, s := range p.spans {
+				if s.begin != begin || s.end-s.begin < p.length/len(p.spans) || s.end-s.begin > p.length/len(p.spans)+1 {
+					t.Fatalf("uneven or overlapping spans: %v", p.spans)
+				}
+				begin = s.end
+			}
+			if begin != p.length {
+				t.Fatalf("spans end at %d of %d elements", begin, p.length)
+			}
+		})
+	}
+}
+
+func TestKernels(t *testing.T) {
+	tests := map[string]struct {
+		input []int64
+	}{
+		"empty":  {input: nil},
+		"tail":   {input: sliceGenerator(7)},
+		"small":  {input: sliceGenerator(10)},
+		"medium": {input: sliceGenerator(1004)},
+		"large":  {input: sliceGenerator(1000001)},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := plainSum(tc.input)
+			if got := unrolledSum(tc.input); got != s {
+				t.Fatalf("unrolledSum: expected: %v, got: %v", s, got)
+			}
+			if got := mapReduce(tc.input, 10, unrolled()); got != s {
+				t.Fatalf("unrolled: expected: %v, got: %v", s, got)
+			}
+			if got, err := checkedSum(tc.input, 10); err != nil || got != s {
+				t.Fatalf("checkedSum: expected: %v, got: %v (%v)", s, got, err)
+			}
+			if got := bigSum(tc.input, 10); got.Cmp(big.NewInt(s)) != 0 {
+				t.Fatalf("bigSum: expected: %v, got: %v", s, got)
+			}
+		})
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	many := make([]int64, 1000)
+	for i := range many {
+		many[i] = math.MaxInt64
+	}
+	tests := map[string]struct {
+		input []int64
+		want  string // the exact sum
+	}{
+		"max plus one":      {input: []int64{math.MaxInt64, 1}, want: "9223372036854775808"},
+		"min minus one":     {input: []int64{math.MinInt64, -1}, want: "-9223372036854775809"},
+		"back in range":     {input: []int64{math.MaxInt64, 1, -1}, want: "9223372036854775807"},
+		"min and max":       {input: []int64{math.MinInt64, math.MaxInt64, math.MinInt64, math.MaxInt64}, want: "-2"},
+		"many max":          {input: many, want: "9223372036854775807000"},
+		"negative overflow": {input: []int64{math.MinInt64, math.MinInt64, math.MinInt64}, want: "-27670116110564327424"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			want, _ := new(big.Int).SetString(tc.want, 10)
+			for _, chunks := range []int{1, 2, 3} {
+				if got := bigSum(tc.input, chunks); got.Cmp(want) != 0 {
+					t.Fatalf("bigSum with %d chunks: expected: %v, got: %v", chunks, want, got)
+				}
+				got, err := checkedSum(tc.input, chunks)
+				if want.IsInt64() {
+					if err != nil || got != want.Int64() {
+						t.Fatalf("checkedSum with %d chunks: expected: %v, got: %v (%v)", chunks, want, got, err)
+					}
+				} else if !errors.Is(err, errOverflow) {
+					t.Fatalf("checkedSum with %d chunks: expected: %v, got: %v", chunks, errOverflow, err)
+				}
+			}
 		})
 	}
 }
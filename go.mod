@@ -1,6 +1,6 @@
 module github.com/shmsr/x
 
-go 1.15
+go 1.21
 
 require (
 	github.com/smartystreets/goconvey v1.6.4 // indirect
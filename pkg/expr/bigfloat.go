@@ -0,0 +1,231 @@
+package expr
+
+import (
+	"math"
+	"math/big"
+)
+
+// bigEval evaluates a node as a binary floating-point number with prec bits
+// of mantissa
+type bigEval interface {
+	EvalBig(env Env, prec uint) (*big.Float, error)
+}
+
+// evaluateBig evaluates e through bigEval
+func evaluateBig(e Node, env Env, prec uint) (*big.Float, error) {
+	be, ok := e.(bigEval)
+	if !ok {
+		return nil, numberExpected("big", e)
+	}
+	return be.EvalBig(env, prec)
+}
+
+// bigOperands evaluates both sides of a binary node with prec bits
+func bigOperands(left, right Node, env Env, prec uint) (*big.Float, *big.Float, error) {
+	l, err := evaluateBig(left, env, prec)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := evaluateBig(right, env, prec)
+	if err != nil {
+		return nil, nil, err
+	}
+	return l, r, nil
+}
+
+// finiteBig turns an infinite result computed by e into an OverflowError,
+// and so, like rat mode, one whose binary exponent exceeds maxExactBits,
+// since printing it takes time that grows with the exponent
+func finiteBig(e Node, f *big.Float) (*big.Float, error) {
+	if exp := f.MantExp(nil); f.IsInf() || exp > maxExactBits || exp < -maxExactBits {
+		return nil, &OverflowError{Expr: String(e)}
+	}
+	return f, nil
+}
+
+// bigFromFloat64 converts a float64 value, keeping float64 precision so
+// the result isn't printed with more digits than it actually has
+func bigFromFloat64(e Node, v float64) (*big.Float, error) {
+	if _, err := finite(e, v); err != nil {
+		return nil, err
+	}
+	return new(big.Float).SetFloat64(v), nil
+}
+
+func (c *Constant) EvalBig(env Env, prec uint) (*big.Float, error) {
+	if c.text != "" {
+		if f, _, err := big.ParseFloat(c.text, 10, prec, big.ToNearestEven); err == nil {
+			return f, nil
+		}
+	}
+	return bigFromFloat64(c, c.Value)
+}
+
+func (v *Variable) EvalBig(env Env, prec uint) (*big.Float, error) {
+	switch value := env[v.Name].(type) {
+	case *big.Float:
+		return value, nil
+	case *big.Rat:
+		return new(big.Float).SetPrec(prec).SetRat(value), nil
+	case float64:
+		return bigFromFloat64(v, value)
+	}
+	return nil, v.notNumber(env)
+}
+
+func (bp *BinaryPlus) EvalBig(env Env, prec uint) (*big.Float, error) {
+	l, r, err := bigOperands(bp.Left, bp.Right, env, prec)
+	if err != nil {
+		return nil, err
+	}
+	return finiteBig(bp, new(big.Float).SetPrec(prec).Add(l, r))
+}
+
+func (bp *BinaryMinus) EvalBig(env Env, prec uint) (*big.Float, error) {
+	l, r, err := bigOperands(bp.Left, bp.Right, env, prec)
+	if err != nil {
+		return nil, err
+	}
+	return finiteBig(bp, new(big.Float).SetPrec(prec).Sub(l, r))
+}
+
+func (bp *BinaryMultiply) EvalBig(env Env, prec uint) (*big.Float, error) {
+	l, r, err := bigOperands(bp.Left, bp.Right, env, prec)
+	if err != nil {
+		return nil, err
+	}
+	return finiteBig(bp, new(big.Float).SetPrec(prec).Mul(l, r))
+}
+
+func (bp *BinaryDivide) EvalBig(env Env, prec uint) (*big.Float, error) {
+	l, r, err := bigOperands(bp.Left, bp.Right, env, prec)
+	if err != nil {
+		return nil, err
+	}
+	if r.Sign() == 0 {
+		return nil, &DivisionByZeroError{Expr: String(bp)}
+	}
+	return finiteBig(bp, new(big.Float).SetPrec(prec).Quo(l, r))
+}
+
+func (bp *BinaryModulo) EvalBig(env Env, prec uint) (*big.Float, error) {
+	l, r, err := bigOperands(bp.Left, bp.Right, env, prec)
+	if err != nil {
+		return nil, err
+	}
+	if r.Sign() == 0 {
+		return nil, &DivisionByZeroError{Expr: String(bp)}
+	}
+	// An infinite quotient has no integer part to subtract
+	q := new(big.Float).SetPrec(prec).Quo(l, r)
+	if l.IsInf() || r.IsInf() || q.IsInf() {
+		return nil, &OverflowError{Expr: String(bp)}
+	}
+	n, _ := q.Int(nil)
+	t := new(big.Float).SetPrec(prec).SetInt(n)
+	return finiteBig(bp, new(big.Float).SetPrec(prec).Sub(l, t.Mul(t, r)))
+}
+
+// EvalBig is exact up to prec for integer exponents and square roots; other
+// exponents fall back to float64 precision
+func (bp *BinaryPower) EvalBig(env Env, prec uint) (*big.Float, error) {
+	l, r, err := bigOperands(bp.Left, bp.Right, env, prec)
+	if err != nil {
+		return nil, err
+	}
+	if r.IsInt() && new(big.Float).Abs(r).Cmp(big.NewFloat(maxExactExponent)) <= 0 {
+		n, _ := r.Int64()
+		if l.Sign() == 0 && n < 0 {
+			return nil, &DivisionByZeroError{Expr: String(bp)}
+		}
+		return finiteBig(bp, bigPow(l, n, prec))
+	}
+	if r.Cmp(big.NewFloat(0.5)) == 0 {
+		if l.Sign() < 0 {
+			return nil, &DomainError{Expr: String(bp)}
+		}
+		return new(big.Float).SetPrec(prec).Sqrt(l), nil
+	}
+	lf, _ := l.Float64()
+	rf, _ := r.Float64()
+	return bigFromFloat64(bp, math.Pow(lf, rf))
+}
+
+// bigPow raises x to the integer power n by repeated squaring
+func bigPow(x *big.Float, n int64, prec uint) *big.Float {
+	neg := n < 0
+	if neg {
+		n = -n
+	}
+	result := new(big.Float).SetPrec(prec).SetInt64(1)
+	square := new(big.Float).SetPrec(prec).Set(x)
+	for ; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			result.Mul(result, square)
+		}
+		square.Mul(square, square)
+	}
+	if neg {
+		result.Quo(new(big.Float).SetPrec(prec).SetInt64(1), result)
+	}
+	return result
+}
+
+func (um *UnaryMinus) EvalBig(env Env, prec uint) (*big.Float, error) {
+	v, err := evaluateBig(um.Operand, env, prec)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Float).SetPrec(prec).Neg(v), nil
+}
+
+// EvalBig computes sqrt and the exact functions with full precision; the
+// remaining functions are evaluated in float64 and widened
+func (c *Call) EvalBig(env Env, prec uint) (*big.Float, error) {
+	args := make([]*big.Float, len(c.Args))
+	for i, arg := range c.Args {
+		v, err := evaluateBig(arg, env, prec)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	if c.Fn.Name == "sqrt" {
+		if args[0].Sign() < 0 {
+			return nil, &DomainError{Expr: String(c)}
+		}
+		return new(big.Float).SetPrec(prec).Sqrt(args[0]), nil
+	}
+	if fn, ok := exactFunctions[c.Fn.Name]; ok {
+		rats := make([]*big.Rat, len(args))
+		for i, arg := range args {
+			rats[i], _ = arg.Rat(nil)
+		}
+		return new(big.Float).SetPrec(prec).SetRat(fn(rats)), nil
+	}
+
+	floats := make([]float64, len(args))
+	for i, arg := range args {
+		floats[i], _ = arg.Float64()
+	}
+	return bigFromFloat64(c, c.Fn.Fn(floats))
+}
+
+// formatBig prints f with one decimal digit fewer than its precision
+// carries, so rounding noise in the last bits doesn't show
+func formatBig(f *big.Float) string {
+	digits := int(float64(f.Prec())*math.Log10(2)) - 1
+	if digits < 1 {
+		digits = 1
+	}
+	return f.Text('g', digits)
+}
+
+func (c *Conditional) EvalBig(env Env, prec uint) (*big.Float, error) {
+	b, err := c.branch(env, bigNumbers(prec))
+	if err != nil {
+		return nil, err
+	}
+	return evaluateBig(b, env, prec)
+}
@@ -0,0 +1,142 @@
+package expr
+
+import (
+	"math"
+)
+
+// checkedEval is the evaluation path that reports failures as errors
+// instead of returning Inf/NaN or panicking on nodes that lack Eval
+type checkedEval interface {
+	EvalChecked(env Env) (float64, error)
+}
+
+// evaluate evaluates e through checkedEval
+func evaluate(e Node, env Env) (float64, error) {
+	ce, ok := e.(checkedEval)
+	if !ok {
+		return 0, numberExpected("eval", e)
+	}
+	return ce.EvalChecked(env)
+}
+
+// operands evaluates both sides of a binary node
+func operands(left, right Node, env Env) (float64, float64, error) {
+	l, err := evaluate(left, env)
+	if err != nil {
+		return 0, 0, err
+	}
+	r, err := evaluate(right, env)
+	if err != nil {
+		return 0, 0, err
+	}
+	return l, r, nil
+}
+
+// finite turns an infinite result computed by e into an OverflowError and
+// a NaN into a DomainError
+func finite(e Node, v float64) (float64, error) {
+	if math.IsInf(v, 0) {
+		return 0, &OverflowError{Expr: String(e)}
+	}
+	if math.IsNaN(v) {
+		return 0, &DomainError{Expr: String(e)}
+	}
+	return v, nil
+}
+
+func (c *Constant) EvalChecked(env Env) (float64, error) {
+	return c.Value, nil
+}
+
+func (v *Variable) EvalChecked(env Env) (float64, error) {
+	value, ok := env.float(v.Name)
+	if !ok {
+		return 0, v.notNumber(env)
+	}
+	return value, nil
+}
+
+func (bp *BinaryPlus) EvalChecked(env Env) (float64, error) {
+	l, r, err := operands(bp.Left, bp.Right, env)
+	if err != nil {
+		return 0, err
+	}
+	return finite(bp, l+r)
+}
+
+func (bp *BinaryMinus) EvalChecked(env Env) (float64, error) {
+	l, r, err := operands(bp.Left, bp.Right, env)
+	if err != nil {
+		return 0, err
+	}
+	return finite(bp, l-r)
+}
+
+func (bp *BinaryMultiply) EvalChecked(env Env) (float64, error) {
+	l, r, err := operands(bp.Left, bp.Right, env)
+	if err != nil {
+		return 0, err
+	}
+	return finite(bp, l*r)
+}
+
+func (bp *BinaryDivide) EvalChecked(env Env) (float64, error) {
+	l, r, err := operands(bp.Left, bp.Right, env)
+	if err != nil {
+		return 0, err
+	}
+	if r == 0 {
+		return 0, &DivisionByZeroError{Expr: String(bp)}
+	}
+	return finite(bp, l/r)
+}
+
+func (bp *BinaryModulo) EvalChecked(env Env) (float64, error) {
+	l, r, err := operands(bp.Left, bp.Right, env)
+	if err != nil {
+		return 0, err
+	}
+	if r == 0 {
+		return 0, &DivisionByZeroError{Expr: String(bp)}
+	}
+	return finite(bp, math.Mod(l, r))
+}
+
+func (bp *BinaryPower) EvalChecked(env Env) (float64, error) {
+	l, r, err := operands(bp.Left, bp.Right, env)
+	if err != nil {
+		return 0, err
+	}
+	if l == 0 && r < 0 {
+		return 0, &DivisionByZeroError{Expr: String(bp)}
+	}
+	return finite(bp, math.Pow(l, r))
+}
+
+func (um *UnaryMinus) EvalChecked(env Env) (float64, error) {
+	v, err := evaluate(um.Operand, env)
+	if err != nil {
+		return 0, err
+	}
+	return -v, nil
+}
+
+func (c *Call) EvalChecked(env Env) (float64, error) {
+	args := make([]float64, len(c.Args))
+	for i, arg := range c.Args {
+		v, err := evaluate(arg, env)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = v
+	}
+	return finite(c, c.Fn.Fn(args))
+}
+
+func (c *Conditional) EvalChecked(env Env) (float64, error) {
+	b, err := c.branch(env, floatNumbers)
+	if err != nil {
+		return 0, err
+	}
+	return evaluate(b, env)
+}
@@ -0,0 +1,42 @@
+package expr
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEvaluateErrors(t *testing.T) {
+	env := Env{"x": 1.0, "big": 1e308, "flag": true}
+
+	tests := map[string]struct {
+		src string
+		err error // the typed error evaluate returns, found with errors.As
+	}{
+		"division by zero":   {src: "2 + 1 / (x - x)", err: &DivisionByZeroError{Expr: "(1 / (x - x))"}},
+		"modulo by zero":     {src: "5 % (x - 1)", err: &DivisionByZeroError{Expr: "(5 % (x - 1))"}},
+		"overflow":           {src: "1 + big * 10", err: &OverflowError{Expr: "(big * 10)"}},
+		"overflowing power":  {src: "10 ^ 400", err: &OverflowError{Expr: "(10 ^ 400)"}},
+		"domain":             {src: "(-8) ^ 0.5", err: &DomainError{Expr: "((-8) ^ 0.5)"}},
+		"undefined variable": {src: "x + y", err: &UndefinedError{Name: "y"}},
+		"bool variable":      {src: "flag * 2", err: &TypeError{Expr: "flag", Want: typeNumber, Got: typeBool}},
+		"first error wins":   {src: "y / 0", err: &UndefinedError{Name: "y"}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = evaluate(e, env)
+			target := reflect.New(reflect.TypeOf(tc.err))
+			if !errors.As(err, target.Interface()) {
+				t.Fatalf("expected: %T, got: %v", tc.err, err)
+			}
+			if got := target.Elem().Interface(); !reflect.DeepEqual(tc.err, got) {
+				t.Fatalf("expected: %v, got: %v", tc.err, got)
+			}
+		})
+	}
+}
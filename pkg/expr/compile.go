@@ -0,0 +1,466 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+type opcode uint8
+
+const (
+	opConst opcode = iota // push consts[arg]
+	opLoad                // push the value bound to variable slot arg
+	opAdd
+	opSub
+	opMul
+	opDiv
+	opMod
+	opPow
+	opNeg
+	opCall      // pop calls[arg].argc values and push calls[arg].fn of them
+	opLess      // pop two values and push 1 if the first is less, 0 otherwise
+	opLessEqual // like opLess for less or equal
+	opEqual     // like opLess for equal
+	opNot       // replace the top with 1 if it is 0 and with 0 otherwise
+	opJump      // continue at arg
+	opJumpFalse // pop the top and continue at arg if it is 0
+	opAnd       // continue at arg if the top is 0, keeping it, or pop it
+	opOr        // continue at arg if the top isn't 0, keeping it, or pop it
+)
+
+var opcodeNames = [...]string{
+	opConst: "const",
+	opLoad:  "load",
+	opAdd:   "add",
+	opSub:   "sub",
+	opMul:   "mul",
+	opDiv:   "div",
+	opMod:   "mod",
+	opPow:   "pow",
+	opNeg:   "neg",
+	opCall:  "call",
+
+	opLess:      "lt",
+	opLessEqual: "le",
+	opEqual:     "eq",
+	opNot:       "not",
+	opJump:      "jmp",
+	opJumpFalse: "jf",
+	opAnd:       "and",
+	opOr:        "or",
+}
+
+func (op opcode) String() string {
+	if int(op) < len(opcodeNames) {
+		return opcodeNames[op]
+	}
+	return fmt.Sprintf("op(%d)", uint8(op))
+}
+
+type instruction struct {
+	op  opcode
+	arg uint32
+}
+
+type callSite struct {
+	fn   *Function
+	argc int
+}
+
+// Program is an expression lowered to instructions for a stack machine.
+// Variables are numbered in the order they first appear; vars maps each
+// slot to its name. Truth values are 1 and 0 on the stack, but like
+// evaluate a program only accepts them where a bool is expected.
+type Program struct {
+	code     []instruction
+	origin   []Node // node each instruction was compiled from, for errors
+	consts   []float64
+	vars     []string
+	slots    map[string]int
+	calls    []callSite
+	depth    int
+	maxDepth int
+}
+
+// compiler lowers a node into p by emitting the instructions that leave
+// its value on top of the stack
+type compiler interface {
+	Compile(p *Program) error
+}
+
+// boolCompiler lowers a node whose value is a truth value, emitting the
+// instructions that leave 1 or 0 on top of the stack
+type boolCompiler interface {
+	CompileBool(p *Program) error
+}
+
+// Compile lowers e into a program
+func Compile(e Node) (*Program, error) {
+	p := &Program{slots: map[string]int{}}
+	if err := p.Compile(e); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Compile appends the instructions that leave the value of e on top of the
+// stack; Compile methods call it for their operands
+func (p *Program) Compile(e Node) error {
+	c, ok := e.(compiler)
+	if !ok {
+		return numberExpected("compile", e)
+	}
+	return c.Compile(p)
+}
+
+// CompileBool compiles e where a bool is expected. Variables bound to
+// bools can't be compiled, since the slots of a program hold numbers.
+func (p *Program) CompileBool(e Node) error {
+	c, ok := e.(boolCompiler)
+	if !ok {
+		if _, isNumber := e.(compiler); isNumber {
+			if _, ok := e.(*Variable); !ok {
+				return &TypeError{Expr: String(e), Want: typeBool, Got: typeNumber}
+			}
+		}
+		return unsupported("compile", e)
+	}
+	return c.CompileBool(p)
+}
+
+// staticType returns the type of the value of e, or of the then branch of
+// a conditional, for comparing it with ==
+func staticType(e Node) string {
+	switch n := e.(type) {
+	case *Conditional:
+		return staticType(n.Then)
+	case boolCompiler:
+		return typeBool
+	}
+	return typeNumber
+}
+
+// emit appends an instruction that pops pops values and pushes one
+func (p *Program) emit(op opcode, arg int, origin Node, pops int) {
+	p.code = append(p.code, instruction{op: op, arg: uint32(arg)})
+	p.origin = append(p.origin, origin)
+	p.depth += 1 - pops
+	if p.depth > p.maxDepth {
+		p.maxDepth = p.depth
+	}
+}
+
+// jump appends a jump that pops pops values, returning its index so that
+// its target can be set with land once it is known
+func (p *Program) jump(op opcode, origin Node, pops int) int {
+	p.code = append(p.code, instruction{op: op})
+	p.origin = append(p.origin, origin)
+	p.depth -= pops
+	return len(p.code) - 1
+}
+
+// land sets the target of the jump at index to the next instruction
+func (p *Program) land(jump int) {
+	p.code[jump].arg = uint32(len(p.code))
+}
+
+// binary compiles both operands followed by op
+func (p *Program) binary(op opcode, origin, left, right Node) error {
+	if err := p.Compile(left); err != nil {
+		return err
+	}
+	if err := p.Compile(right); err != nil {
+		return err
+	}
+	p.emit(op, 0, origin, 2)
+	return nil
+}
+
+// Bind looks up the value of every variable slot in env
+func (p *Program) Bind(env Env) ([]float64, error) {
+	values := make([]float64, len(p.vars))
+	for i, name := range p.vars {
+		v, ok := env.float(name)
+		if !ok {
+			return nil, &UndefinedError{Name: name}
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// String disassembles the program, one instruction per line
+func (p *Program) String() string {
+	var b strings.Builder
+	for pc, in := range p.code {
+		operand := ""
+		switch in.op {
+		case opConst:
+			operand = fmt.Sprint(p.consts[in.arg])
+		case opLoad:
+			operand = p.vars[in.arg]
+		case opCall:
+			operand = fmt.Sprintf("%s/%d", p.calls[in.arg].fn.Name, p.calls[in.arg].argc)
+		case opJump, opJumpFalse, opAnd, opOr:
+			operand = fmt.Sprintf("%04d", in.arg)
+		}
+		line := fmt.Sprintf("%04d  %-5s  %s", pc, in.op, operand)
+		b.WriteString(strings.TrimRight(line, " "))
+		b.WriteByte('\n')
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (c *Constant) Compile(p *Program) error {
+	p.consts = append(p.consts, c.Value)
+	p.emit(opConst, len(p.consts)-1, c, 0)
+	return nil
+}
+
+func (v *Variable) Compile(p *Program) error {
+	slot, ok := p.slots[v.Name]
+	if !ok {
+		slot = len(p.vars)
+		p.slots[v.Name] = slot
+		p.vars = append(p.vars, v.Name)
+	}
+	p.emit(opLoad, slot, v, 0)
+	return nil
+}
+
+func (bp *BinaryPlus) Compile(p *Program) error {
+	return p.binary(opAdd, bp, bp.Left, bp.Right)
+}
+
+func (bp *BinaryMinus) Compile(p *Program) error {
+	return p.binary(opSub, bp, bp.Left, bp.Right)
+}
+
+func (bp *BinaryMultiply) Compile(p *Program) error {
+	return p.binary(opMul, bp, bp.Left, bp.Right)
+}
+
+func (bp *BinaryDivide) Compile(p *Program) error {
+	return p.binary(opDiv, bp, bp.Left, bp.Right)
+}
+
+func (bp *BinaryModulo) Compile(p *Program) error {
+	return p.binary(opMod, bp, bp.Left, bp.Right)
+}
+
+func (bp *BinaryPower) Compile(p *Program) error {
+	return p.binary(opPow, bp, bp.Left, bp.Right)
+}
+
+func (um *UnaryMinus) Compile(p *Program) error {
+	if err := p.Compile(um.Operand); err != nil {
+		return err
+	}
+	p.emit(opNeg, 0, um, 1)
+	return nil
+}
+
+func (c *Call) Compile(p *Program) error {
+	for _, arg := range c.Args {
+		if err := p.Compile(arg); err != nil {
+			return err
+		}
+	}
+	p.calls = append(p.calls, callSite{fn: c.Fn, argc: len(c.Args)})
+	p.emit(opCall, len(p.calls)-1, c, len(c.Args))
+	return nil
+}
+
+func (bp *BinaryLess) CompileBool(p *Program) error {
+	return p.binary(opLess, bp, bp.Left, bp.Right)
+}
+
+func (bp *BinaryLessEqual) CompileBool(p *Program) error {
+	return p.binary(opLessEqual, bp, bp.Left, bp.Right)
+}
+
+// CompileBool compiles both operands as the type of the left one, reporting
+// a TypeError like EvalBool when the right one differs
+func (bp *BinaryEqual) CompileBool(p *Program) error {
+	want, got := staticType(bp.Left), staticType(bp.Right)
+	if want != got {
+		return &TypeError{Expr: String(bp.Right), Want: want, Got: got}
+	}
+	if want == typeNumber {
+		return p.binary(opEqual, bp, bp.Left, bp.Right)
+	}
+	if err := p.CompileBool(bp.Left); err != nil {
+		return err
+	}
+	if err := p.CompileBool(bp.Right); err != nil {
+		return err
+	}
+	p.emit(opEqual, 0, bp, 2)
+	return nil
+}
+
+// logical compiles left and, unless op short-circuits past it, right
+func (p *Program) logical(op opcode, origin, left, right Node) error {
+	if err := p.CompileBool(left); err != nil {
+		return err
+	}
+	skip := p.jump(op, origin, 1)
+	if err := p.CompileBool(right); err != nil {
+		return err
+	}
+	p.land(skip)
+	return nil
+}
+
+func (bp *BinaryAnd) CompileBool(p *Program) error {
+	return p.logical(opAnd, bp, bp.Left, bp.Right)
+}
+
+func (bp *BinaryOr) CompileBool(p *Program) error {
+	return p.logical(opOr, bp, bp.Left, bp.Right)
+}
+
+func (un *UnaryNot) CompileBool(p *Program) error {
+	if err := p.CompileBool(un.Operand); err != nil {
+		return err
+	}
+	p.emit(opNot, 0, un, 1)
+	return nil
+}
+
+// branches compiles the condition and then each branch with compile, which
+// leaves one value on the stack whichever branch runs
+func (c *Conditional) branches(p *Program, compile func(Node) error) error {
+	if err := p.CompileBool(c.Cond); err != nil {
+		return err
+	}
+	otherwise := p.jump(opJumpFalse, c, 1)
+	if err := compile(c.Then); err != nil {
+		return err
+	}
+	end := p.jump(opJump, c, 0)
+	// The otherwise branch starts from the depth before the then branch
+	p.depth--
+	p.land(otherwise)
+	if err := compile(c.Otherwise); err != nil {
+		return err
+	}
+	p.land(end)
+	return nil
+}
+
+func (c *Conditional) Compile(p *Program) error {
+	return c.branches(p, p.Compile)
+}
+
+func (c *Conditional) CompileBool(p *Program) error {
+	return c.branches(p, p.CompileBool)
+}
+
+// VM executes a Program. It reuses its stack between runs, so a VM must
+// not be shared between goroutines; create one per goroutine instead.
+type VM struct {
+	prog  *Program
+	stack []float64
+}
+
+func NewVM(p *Program) *VM {
+	return &VM{prog: p, stack: make([]float64, p.maxDepth)}
+}
+
+// Run executes the program with vars holding the value of each variable
+// slot. It reports the same errors as evaluate on the source tree.
+func (m *VM) Run(vars []float64) (float64, error) {
+	p := m.prog
+	if len(vars) != len(p.vars) {
+		return 0, fmt.Errorf("program needs %d variable(s), got %d", len(p.vars), len(vars))
+	}
+	s := m.stack
+	sp := 0
+	for pc := 0; pc < len(p.code); pc++ {
+		in := p.code[pc]
+		switch in.op {
+		case opConst:
+			s[sp] = p.consts[in.arg]
+			sp++
+			continue
+		case opLoad:
+			s[sp] = vars[in.arg]
+			sp++
+			continue
+		case opAdd:
+			sp--
+			s[sp-1] += s[sp]
+		case opSub:
+			sp--
+			s[sp-1] -= s[sp]
+		case opMul:
+			sp--
+			s[sp-1] *= s[sp]
+		case opDiv:
+			sp--
+			if s[sp] == 0 {
+				return 0, &DivisionByZeroError{Expr: String(p.origin[pc])}
+			}
+			s[sp-1] /= s[sp]
+		case opMod:
+			sp--
+			if s[sp] == 0 {
+				return 0, &DivisionByZeroError{Expr: String(p.origin[pc])}
+			}
+			s[sp-1] = math.Mod(s[sp-1], s[sp])
+		case opPow:
+			sp--
+			if s[sp-1] == 0 && s[sp] < 0 {
+				return 0, &DivisionByZeroError{Expr: String(p.origin[pc])}
+			}
+			s[sp-1] = math.Pow(s[sp-1], s[sp])
+		case opNeg:
+			s[sp-1] = -s[sp-1]
+			continue
+		case opCall:
+			c := p.calls[in.arg]
+			v := c.fn.Fn(s[sp-c.argc : sp])
+			sp -= c.argc
+			s[sp] = v
+			sp++
+		case opLess:
+			sp--
+			s[sp-1] = truth(s[sp-1] < s[sp])
+			continue
+		case opLessEqual:
+			sp--
+			s[sp-1] = truth(s[sp-1] <= s[sp])
+			continue
+		case opEqual:
+			sp--
+			s[sp-1] = truth(s[sp-1] == s[sp])
+			continue
+		case opNot:
+			s[sp-1] = truth(s[sp-1] == 0)
+			continue
+		case opJump:
+			pc = int(in.arg) - 1
+			continue
+		case opJumpFalse:
+			sp--
+			if s[sp] == 0 {
+				pc = int(in.arg) - 1
+			}
+			continue
+		case opAnd, opOr:
+			if (s[sp-1] != 0) == (in.op == opOr) {
+				pc = int(in.arg) - 1
+			} else {
+				sp--
+			}
+			continue
+		}
+		if v := s[sp-1]; math.IsInf(v, 0) || math.IsNaN(v) {
+			_, err := finite(p.origin[pc], v)
+			return 0, err
+		}
+	}
+	return s[0], nil
+}
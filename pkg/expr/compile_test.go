@@ -0,0 +1,151 @@
+package expr
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// largeFormula builds a sum of n terms over x, y and z mixing every operator
+// and a few function calls, so that the tree has several thousand nodes
+func largeFormula(n int) string {
+	terms := make([]string, n)
+	for i := range terms {
+		switch i % 4 {
+		case 0:
+			terms[i] = fmt.Sprintf("x * %d.5", i)
+		case 1:
+			terms[i] = fmt.Sprintf("(y - %d) / (z + %d)", i, i)
+		case 2:
+			terms[i] = fmt.Sprintf("sqrt(x^2 + y^2) %% %d", i)
+		default:
+			terms[i] = fmt.Sprintf("-max(x, y, z) * sin(%d)", i)
+		}
+	}
+	return strings.Join(terms, " + ")
+}
+
+func TestVM(t *testing.T) {
+	tests := map[string]struct {
+		src  string
+		x, y float64
+		err  error // reported by both evaluate and the VM, or by Compile
+	}{
+		"arithmetic":        {src: "1 + 2 * x - y / 4 % 3 ^ 2", x: 2, y: 3},
+		"functions":         {src: "max(x, y, 1) + sqrt(x) * -sin(y)", x: 2, y: 3},
+		"large":             {src: largeFormula(100), x: 2, y: 3},
+		"division by zero":  {src: "1 + x / (y - 3)", x: 2, y: 3, err: &DivisionByZeroError{Expr: "(x / (y - 3))"}},
+		"modulo by zero":    {src: "x % (y - 3) + 1", x: 2, y: 3, err: &DivisionByZeroError{Expr: "(x % (y - 3))"}},
+		"negative power":    {src: "(x - 2) ^ -y", x: 2, y: 3, err: &DivisionByZeroError{Expr: "((x - 2) ^ -y)"}},
+		"overflow":          {src: "y + 2 ^ x", x: 2000, y: 3, err: &OverflowError{Expr: "(2 ^ x)"}},
+		"overflow in call":  {src: "1 / exp(x)", x: 1000, err: &OverflowError{Expr: "exp(x)"}},
+		"domain":            {src: "x + sqrt(y)", x: 2, y: -1, err: &DomainError{Expr: "sqrt(y)"}},
+		"conditional":       {src: "x < y ? x : y", x: 2, y: 3},
+		"untaken branch":    {src: "x <= y ? x : 1 / 0", x: 2, y: 3},
+		"taken branch":      {src: "x == y ? x : 1 / (y - 3)", x: 2, y: 3, err: &DivisionByZeroError{Expr: "(1 / (y - 3))"}},
+		"short circuit":     {src: "x < y || 1 / 0 < 1 ? 1 : 0", x: 2, y: 3},
+		"and":               {src: "x < y && !(y < 4) ? 1 : 0", x: 2, y: 3},
+		"bool equality":     {src: "(x < 1) == (y < 1) ? 1 : 0", x: 2, y: 3},
+		"bool result":       {src: "x < y", x: 2, y: 3, err: &TypeError{Expr: "(x < y)", Want: typeNumber, Got: typeBool}},
+		"bool operand":      {src: "(x < y) + 1", x: 2, y: 3, err: &TypeError{Expr: "(x < y)", Want: typeNumber, Got: typeBool}},
+		"number as bool":    {src: "x + 1 ? 1 : 0", x: 2, y: 3, err: &TypeError{Expr: "(x + 1)", Want: typeBool, Got: typeNumber}},
+		"mixed equality":    {src: "(x < 1) == y ? 1 : 0", x: 2, y: 3, err: &TypeError{Expr: "y", Want: typeBool, Got: typeNumber}},
+		"nested conditions": {src: "x < 1 ? 1 : y < 4 ? (x == 2 ? 2 : 3) : 4", x: 2, y: 3},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			env := Env{"x": tc.x, "y": tc.y, "z": 5.0}
+			want, evalErr := evaluate(e, env)
+			if !reflect.DeepEqual(tc.err, evalErr) {
+				t.Fatalf("evaluate: expected: %v, got: %v (%v)", tc.err, want, evalErr)
+			}
+
+			p, err := Compile(e)
+			if err != nil {
+				// Only errors that don't depend on the bindings are found
+				// without running the program
+				if !reflect.DeepEqual(tc.err, err) {
+					t.Fatalf("compile: expected: %v, got: %v", tc.err, err)
+				}
+				return
+			}
+			vars, err := p.Bind(env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := NewVM(p).Run(vars)
+			if !reflect.DeepEqual(tc.err, err) || got != want {
+				t.Fatalf("expected: %v (%v), got: %v (%v)\n%s", want, tc.err, got, err, p)
+			}
+		})
+	}
+}
+
+func BenchmarkEvaluators(b *testing.B) {
+	env := Env{"x": 1.0, "y": 2.0, "z": 3.0}
+	large, err := Parse(largeFormula(1000))
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarks := map[string]struct {
+		tree Node
+	}{
+		"small": {tree: &BinaryPlus{&Constant{Value: 1}, &BinaryMinus{&Constant{Value: 2}, &Variable{Name: "x"}}}},
+		"large": {tree: large},
+	}
+	for name, bm := range benchmarks {
+		prog, err := Compile(bm.tree)
+		if err != nil {
+			b.Fatal(err)
+		}
+		m := NewVM(prog)
+		vars, err := prog.Bind(env)
+		if err != nil {
+			b.Fatal(err)
+		}
+		want, err := evaluate(bm.tree, env)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if got, err := m.Run(vars); err != nil || got != want {
+			b.Fatalf("%s: expected: %v, got: %v (%v)", name, want, got, err)
+		}
+
+		// rebind changes the bindings on every iteration, the way a caller
+		// evaluating one formula over many inputs would
+		rebind := func(i int) {
+			v := float64(i%100) + 1
+			env["x"], env["y"], env["z"] = v, v+1, v+2
+		}
+		b.Run(fmt.Sprintf("BenchmarkEval: %s", name), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				rebind(i)
+				_ = bm.tree.(eval).Eval(env)
+			}
+		})
+		b.Run(fmt.Sprintf("BenchmarkEvalChecked: %s", name), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				rebind(i)
+				_, _ = evaluate(bm.tree, env)
+			}
+		})
+		b.Run(fmt.Sprintf("BenchmarkVM: %s", name), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				v := float64(i%100) + 1
+				for slot, name := range prog.vars {
+					vars[slot] = v + float64(name[0]-'x')
+				}
+				_, _ = m.Run(vars)
+			}
+		})
+	}
+}
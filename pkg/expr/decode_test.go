@@ -0,0 +1,145 @@
+package expr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestDecodeRoundTrip checks that ParseSExpr and DecodeJSON read back the
+// output of SExpr and JSON as a tree that prints and evaluates the same
+func TestDecodeRoundTrip(t *testing.T) {
+	env := Env{"x": 2.0, "y": 3.0}
+	decoders := map[string]func(Node) (Node, error){
+		"sexpr": func(e Node) (Node, error) {
+			s, err := SExpr(e)
+			if err != nil {
+				return nil, err
+			}
+			return ParseSExpr(s)
+		},
+		"json": func(e Node) (Node, error) {
+			s, err := JSON(e)
+			if err != nil {
+				return nil, err
+			}
+			return DecodeJSON([]byte(s))
+		},
+	}
+	tests := []string{
+		"1 + 2 * x",
+		"-(x - 0.1) ^ -y % 3",
+		"max(x, y, 1) / sqrt(y)",
+		"x < y && !(y <= 1) || x == 2",
+		"x < 1 ? y : -y",
+		"5 km / 2 h to m/s",
+	}
+
+	for name, decode := range decoders {
+		for _, src := range tests {
+			t.Run(name+"/"+src, func(t *testing.T) {
+				e, err := Parse(src)
+				if err != nil {
+					t.Fatal(err)
+				}
+				again, err := decode(e)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := String(again); got != String(e) {
+					t.Fatalf("expected: %s, got: %s", String(e), got)
+				}
+				want, err := Eval(e, env)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := Eval(again, env)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if Format(got) != Format(want) {
+					t.Fatalf("expected: %s, got: %s", Format(want), Format(got))
+				}
+			})
+		}
+	}
+}
+
+func TestParseSExprErrors(t *testing.T) {
+	tests := map[string]struct {
+		src string
+		err error
+	}{
+		"empty":            {src: "", err: &SyntaxError{Pos: 1, Msg: "unexpected end of input"}},
+		"unclosed":         {src: "(+ 1 2", err: &SyntaxError{Pos: 1, Msg: "unclosed '('"}},
+		"trailing":         {src: "(+ 1 2))", err: &SyntaxError{Pos: 8, Msg: "unexpected ')' after the expression"}},
+		"stray paren":      {src: ")", err: &SyntaxError{Pos: 1, Msg: "unexpected ')'"}},
+		"empty list":       {src: "()", err: &SyntaxError{Pos: 2, Msg: "expected an operator or function name after '('"}},
+		"bad atom":         {src: "(+ 1 $)", err: &SyntaxError{Pos: 6, Msg: `"$" is not a number or variable`}},
+		"operand count":    {src: "(+ 1)", err: &SyntaxError{Pos: 2, Msg: "+ expects 2 operands, got 1"}},
+		"negation count":   {src: "(neg 1 2)", err: &SyntaxError{Pos: 2, Msg: "neg expects 1 operand, got 2"}},
+		"if count":         {src: "(if 1 2)", err: &SyntaxError{Pos: 2, Msg: "if expects 3 operands, got 2"}},
+		"unknown head":     {src: "(frob 1)", err: &SyntaxError{Pos: 2, Msg: `unknown operator or function "frob"`}},
+		"function arity":   {src: "(sqrt 1 2)", err: &SyntaxError{Pos: 2, Msg: "sqrt expects 1 argument(s), got 2"}},
+		"unknown unit":     {src: "(* 1 (unit parsec))", err: &SyntaxError{Pos: 7, Msg: `unknown unit "parsec"`}},
+		"unit of a number": {src: "(unit 1)", err: &SyntaxError{Pos: 2, Msg: "unit expects 1 unit symbol"}},
+		"too deep": {
+			src: strings.Repeat("(neg ", maxSExprDepth+1) + "x" + strings.Repeat(")", maxSExprDepth+1),
+			err: &SyntaxError{Pos: 5*maxSExprDepth + 1, Msg: "lists nested deeper than 10000"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseSExpr(tc.src); !reflect.DeepEqual(tc.err, err) {
+				t.Fatalf("expected: %v, got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestParseSExprDepth(t *testing.T) {
+	src := strings.Repeat("(neg ", maxSExprDepth) + "x" + strings.Repeat(")", maxSExprDepth)
+	e, err := ParseSExpr(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := EvalFloat(e, Env{"x": 1.0}); err != nil || v != 1 {
+		t.Fatalf("expected: 1, got: %v (%v)", v, err)
+	}
+}
+
+func TestDecodeJSONErrors(t *testing.T) {
+	one := `{"kind": "constant", "value": 1}`
+	tests := map[string]struct {
+		src string
+		err error
+	}{
+		"malformed":        {src: `{"kind": "neg"`, err: &DecodeError{Msg: "unexpected EOF"}},
+		"unknown field":    {src: `{"kind": "constant", "value": 1, "extra": 2}`, err: &DecodeError{Msg: `json: unknown field "extra"`}},
+		"trailing data":    {src: one + " {}", err: &DecodeError{Msg: "unexpected data after the root node"}},
+		"unknown kind":     {src: `{"kind": "frob"}`, err: &DecodeError{Msg: `unknown kind "frob"`}},
+		"missing value":    {src: `{"kind": "constant"}`, err: &DecodeError{Msg: "constant needs a value and nothing else"}},
+		"bad name":         {src: `{"kind": "variable", "name": "1x"}`, err: &DecodeError{Msg: "variable needs a valid name and nothing else"}},
+		"operand count":    {src: `{"kind": "+", "args": [` + one + `]}`, err: &DecodeError{Msg: "+ needs exactly 2 args and no name"}},
+		"null operand":     {src: `{"kind": "neg", "args": [null]}`, err: &DecodeError{Msg: "args[0] is null"}},
+		"unknown function": {src: `{"kind": "call", "name": "nope"}`, err: &DecodeError{Msg: `unknown function "nope"`}},
+		"function arity":   {src: `{"kind": "call", "name": "sqrt"}`, err: &DecodeError{Msg: "sqrt expects 1 argument(s), got 0"}},
+		"nested path": {
+			src: `{"kind": "+", "args": [` + one + `, {"kind": "neg", "args": [{"kind": "unit", "name": "parsec"}]}]}`,
+			err: &DecodeError{Path: "args[1].args[0]", Msg: `unknown unit "parsec"`},
+		},
+		"too deep": {
+			src: strings.Repeat(`{"kind": "neg", "args": [`, 10001) + one + strings.Repeat("]}", 10001),
+			err: &DecodeError{Msg: "exceeded max depth"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := DecodeJSON([]byte(tc.src)); !reflect.DeepEqual(tc.err, err) {
+				t.Fatalf("expected: %v, got: %v", tc.err, err)
+			}
+		})
+	}
+}
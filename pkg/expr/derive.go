@@ -0,0 +1,270 @@
+package expr
+
+// differentiator produces the derivative of a node with respect to the
+// variable named x. Derivatives share subtrees with the original tree and,
+// apart from leaving out terms that are 0, are unsimplified; pass them
+// through Simplify for a readable form.
+type differentiator interface {
+	Derive(x string) (Node, error)
+}
+
+// Derive differentiates e with respect to x
+func Derive(e Node, x string) (Node, error) {
+	d, ok := e.(differentiator)
+	if !ok {
+		return nil, unsupported("derive", e)
+	}
+	return d.Derive(x)
+}
+
+// deriveOperands differentiates both sides of a binary node
+func deriveOperands(left, right Node, x string) (Node, Node, error) {
+	dl, err := Derive(left, x)
+	if err != nil {
+		return nil, nil, err
+	}
+	dr, err := Derive(right, x)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dl, dr, nil
+}
+
+// apply builds a call to the registered function name
+func apply(name string, args ...Node) Node {
+	fn, _ := LookupFunction(name)
+	return &Call{Fn: fn, Args: args}
+}
+
+func num(v float64) Node {
+	return &Constant{Value: v}
+}
+
+// The builders below leave out terms that are the constant 0, so that the
+// parts of a derivative that don't depend on x vanish instead of being
+// kept as 0 * u
+
+// plus builds a + b
+func plus(a, b Node) Node {
+	switch {
+	case isConstant(a, 0):
+		return b
+	case isConstant(b, 0):
+		return a
+	}
+	return &BinaryPlus{a, b}
+}
+
+// minus builds a - b
+func minus(a, b Node) Node {
+	switch {
+	case isConstant(b, 0):
+		return a
+	case isConstant(a, 0):
+		return &UnaryMinus{b}
+	}
+	return &BinaryMinus{a, b}
+}
+
+// times builds a * b
+func times(a, b Node) Node {
+	if isConstant(a, 0) || isConstant(b, 0) {
+		return num(0)
+	}
+	return &BinaryMultiply{a, b}
+}
+
+// over builds a / b
+func over(a, b Node) Node {
+	if isConstant(a, 0) {
+		return num(0)
+	}
+	return &BinaryDivide{a, b}
+}
+
+func (c *Constant) Derive(x string) (Node, error) {
+	return num(0), nil
+}
+
+func (v *Variable) Derive(x string) (Node, error) {
+	if v.Name == x {
+		return num(1), nil
+	}
+	return num(0), nil
+}
+
+func (bp *BinaryPlus) Derive(x string) (Node, error) {
+	dl, dr, err := deriveOperands(bp.Left, bp.Right, x)
+	if err != nil {
+		return nil, err
+	}
+	return plus(dl, dr), nil
+}
+
+func (bp *BinaryMinus) Derive(x string) (Node, error) {
+	dl, dr, err := deriveOperands(bp.Left, bp.Right, x)
+	if err != nil {
+		return nil, err
+	}
+	return minus(dl, dr), nil
+}
+
+// Derive applies the product rule: (uv)' = u'v + uv', leaving out the
+// term of a factor that doesn't depend on x
+func (bp *BinaryMultiply) Derive(x string) (Node, error) {
+	dl, dr, err := deriveOperands(bp.Left, bp.Right, x)
+	if err != nil {
+		return nil, err
+	}
+	return plus(times(dl, bp.Right), times(bp.Left, dr)), nil
+}
+
+// Derive applies the quotient rule: (u/v)' = (u'v - uv') / v^2, or u'/v
+// when v doesn't depend on x
+func (bp *BinaryDivide) Derive(x string) (Node, error) {
+	dl, dr, err := deriveOperands(bp.Left, bp.Right, x)
+	if err != nil {
+		return nil, err
+	}
+	if isConstant(dr, 0) {
+		return over(dl, bp.Right), nil
+	}
+	return over(
+		minus(times(dl, bp.Right), times(bp.Left, dr)),
+		&BinaryPower{bp.Right, num(2)},
+	), nil
+}
+
+// Derive uses u % v = u - v*trunc(u/v), where trunc is piecewise constant:
+// (u % v)' = u' - v'*trunc(u/v)
+func (bp *BinaryModulo) Derive(x string) (Node, error) {
+	dl, dr, err := deriveOperands(bp.Left, bp.Right, x)
+	if err != nil {
+		return nil, err
+	}
+	return minus(dl, times(dr, apply("trunc", &BinaryDivide{bp.Left, bp.Right}))), nil
+}
+
+// Derive uses the power rule for constant exponents, the exponential rule
+// for constant bases and the general form u^v * (v'*log(u) + v*u'/u)
+// otherwise
+func (bp *BinaryPower) Derive(x string) (Node, error) {
+	dl, dr, err := deriveOperands(bp.Left, bp.Right, x)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := constantValue(bp.Right); ok {
+		return times(&BinaryMultiply{num(c), &BinaryPower{bp.Left, num(c - 1)}}, dl), nil
+	}
+	if _, ok := constantValue(bp.Left); ok {
+		return times(&BinaryMultiply{bp, apply("log", bp.Left)}, dr), nil
+	}
+	return times(bp, plus(
+		times(dr, apply("log", bp.Left)),
+		over(times(bp.Right, dl), bp.Left),
+	)), nil
+}
+
+func (um *UnaryMinus) Derive(x string) (Node, error) {
+	d, err := Derive(um.Operand, x)
+	if err != nil {
+		return nil, err
+	}
+	return minus(num(0), d), nil
+}
+
+// derivatives maps a function name to its derivative with respect to each
+// argument; the chain rule in Call.Derive multiplies these by the
+// derivatives of the arguments
+var derivatives = map[string]func(args []Node) []Node{
+	"abs": func(a []Node) []Node {
+		return []Node{&BinaryDivide{a[0], apply("abs", a[0])}}
+	},
+	"sqrt": func(a []Node) []Node {
+		return []Node{&BinaryDivide{num(1), &BinaryMultiply{num(2), apply("sqrt", a[0])}}}
+	},
+	"cbrt": func(a []Node) []Node {
+		return []Node{&BinaryDivide{num(1), &BinaryMultiply{num(3), &BinaryPower{apply("cbrt", a[0]), num(2)}}}}
+	},
+	"exp": func(a []Node) []Node {
+		return []Node{apply("exp", a[0])}
+	},
+	"log": func(a []Node) []Node {
+		return []Node{&BinaryDivide{num(1), a[0]}}
+	},
+	"log2": func(a []Node) []Node {
+		return []Node{&BinaryDivide{num(1), &BinaryMultiply{a[0], apply("log", num(2))}}}
+	},
+	"log10": func(a []Node) []Node {
+		return []Node{&BinaryDivide{num(1), &BinaryMultiply{a[0], apply("log", num(10))}}}
+	},
+	"sin": func(a []Node) []Node {
+		return []Node{apply("cos", a[0])}
+	},
+	"cos": func(a []Node) []Node {
+		return []Node{&UnaryMinus{apply("sin", a[0])}}
+	},
+	"tan": func(a []Node) []Node {
+		return []Node{&BinaryDivide{num(1), &BinaryPower{apply("cos", a[0]), num(2)}}}
+	},
+	"asin": func(a []Node) []Node {
+		return []Node{&BinaryDivide{num(1), apply("sqrt", &BinaryMinus{num(1), &BinaryPower{a[0], num(2)}})}}
+	},
+	"acos": func(a []Node) []Node {
+		return []Node{&UnaryMinus{&BinaryDivide{num(1), apply("sqrt", &BinaryMinus{num(1), &BinaryPower{a[0], num(2)}})}}}
+	},
+	"atan": func(a []Node) []Node {
+		return []Node{&BinaryDivide{num(1), &BinaryPlus{num(1), &BinaryPower{a[0], num(2)}}}}
+	},
+	"atan2": func(a []Node) []Node {
+		// atan2(y, x): d/dy = x/(x^2+y^2), d/dx = -y/(x^2+y^2)
+		sq := &BinaryPlus{&BinaryPower{a[1], num(2)}, &BinaryPower{a[0], num(2)}}
+		return []Node{&BinaryDivide{a[1], sq}, &UnaryMinus{&BinaryDivide{a[0], sq}}}
+	},
+	"hypot": func(a []Node) []Node {
+		h := apply("hypot", a[0], a[1])
+		return []Node{&BinaryDivide{a[0], h}, &BinaryDivide{a[1], h}}
+	},
+	"floor": func(a []Node) []Node { return []Node{num(0)} },
+	"ceil":  func(a []Node) []Node { return []Node{num(0)} },
+	"round": func(a []Node) []Node { return []Node{num(0)} },
+	"trunc": func(a []Node) []Node { return []Node{num(0)} },
+}
+
+// Derive applies the chain rule: f(u1, ..., un)' = sum of df/dui * ui'.
+// Functions without an entry in derivatives, such as min and max, are
+// reported as unsupported.
+func (c *Call) Derive(x string) (Node, error) {
+	partials, ok := derivatives[c.Fn.Name]
+	if !ok {
+		return nil, unsupported("derive", c)
+	}
+	var sum Node
+	for i, df := range partials(c.Args) {
+		du, err := Derive(c.Args[i], x)
+		if err != nil {
+			return nil, err
+		}
+		term := times(df, du)
+		if sum == nil {
+			sum = term
+			continue
+		}
+		sum = plus(sum, term)
+	}
+	return sum, nil
+}
+
+// Derive differentiates each piece of a piecewise function separately,
+// ignoring the points where the condition changes
+func (c *Conditional) Derive(x string) (Node, error) {
+	dt, do, err := deriveOperands(c.Then, c.Otherwise, x)
+	if err != nil {
+		return nil, err
+	}
+	return &Conditional{c.Cond, dt, do}, nil
+}
+
+func (u *UnitLiteral) Derive(x string) (Node, error) {
+	return num(0), nil
+}
@@ -0,0 +1,73 @@
+package expr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDerive(t *testing.T) {
+	tests := map[string]struct {
+		src        string
+		derivative string // ToString of the derivative
+		simplified string // ToString of the simplified derivative
+	}{
+		"constant":          {src: "3", derivative: "0", simplified: "0"},
+		"other variable":    {src: "y", derivative: "0", simplified: "0"},
+		"sum":               {src: "x + y", derivative: "1", simplified: "1"},
+		"difference":        {src: "y - x", derivative: "-1", simplified: "-1"},
+		"product":           {src: "3 * x", derivative: "(3 * 1)", simplified: "3"},
+		"product of others": {src: "y * z", derivative: "0", simplified: "0"},
+		"quotient":          {src: "x / y", derivative: "(1 / y)", simplified: "(1 / y)"},
+		"reciprocal":        {src: "1 / x", derivative: "(-(1 * 1) / (x ^ 2))", simplified: "(-1 / (x ^ 2))"},
+		"modulo":            {src: "x % 3", derivative: "1", simplified: "1"},
+		"power":             {src: "x ^ 3", derivative: "((3 * (x ^ 2)) * 1)", simplified: "(3 * (x ^ 2))"},
+		"exponential":       {src: "2 ^ x", derivative: "(((2 ^ x) * log(2)) * 1)", simplified: "(0.6931471805599453 * (2 ^ x))"},
+		"general power":     {src: "x ^ y", derivative: "((x ^ y) * ((y * 1) / x))", simplified: "((x ^ y) * (y / x))"},
+		"negation":          {src: "-(x * y)", derivative: "-(1 * y)", simplified: "-y"},
+		"chain rule":        {src: "log(x^2 + 1)", derivative: "((1 / ((x ^ 2) + 1)) * ((2 * (x ^ 1)) * 1))", simplified: "((2 * x) * (1 / (1 + (x ^ 2))))"},
+		"call of others":    {src: "sin(y)", derivative: "0", simplified: "0"},
+		"two arguments":     {src: "atan2(y, x)", derivative: "(-(y / ((x ^ 2) + (y ^ 2))) * 1)", simplified: "-(y / ((x ^ 2) + (y ^ 2)))"},
+		"piecewise":         {src: "x < 0 ? -x : x", derivative: "((x < 0) ? -1 : 1)", simplified: "((x < 0) ? -1 : 1)"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, err := Derive(e, "x")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := String(d); got != tc.derivative {
+				t.Fatalf("expected: %s, got: %s", tc.derivative, got)
+			}
+			if got := String(Simplify(d)); got != tc.simplified {
+				t.Fatalf("expected simplified: %s, got: %s", tc.simplified, got)
+			}
+		})
+	}
+}
+
+func TestDeriveErrors(t *testing.T) {
+	tests := map[string]struct {
+		src string
+		err error
+	}{
+		"min":  {src: "1 + min(x, 2)", err: &UnsupportedError{Op: "derive", Node: "*expr.Call", Expr: "min(x, 2)"}},
+		"bool": {src: "x < 1", err: &UnsupportedError{Op: "derive", Node: "*expr.BinaryLess", Expr: "(x < 1)"}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Derive(e, "x"); !reflect.DeepEqual(tc.err, err) {
+				t.Fatalf("expected: %v, got: %v", tc.err, err)
+			}
+		})
+	}
+}
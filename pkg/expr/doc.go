@@ -0,0 +1,26 @@
+// Package expr parses and evaluates arithmetic expressions with variables,
+// functions, comparisons and units.
+//
+// Trees are built from the exported node types, either directly or with
+// Parse, and every operation on them is an interface that the node types
+// implement separately, following
+// https://eli.thegreenplace.net/2018/the-expression-problem-in-go/.
+// A node type defined outside the package takes part in an operation by
+// implementing its method, such as ToString() string, EvalChecked(env Env)
+// (float64, error) or Children() []Node; operations it doesn't implement
+// return an UnsupportedError. Operations that build up state pass it in an
+// exported type: ToDOT(g *DOTGraph) int adds the node with g.Leaf or
+// g.Operator, Compile(p *Program) error compiles operands with p.Compile,
+// ToJSON() (*JSONNode, error) converts them with JSONOf, and
+// EvalBool(env Env, num Numeric) (bool, error) evaluates them with num in
+// the selected mode.
+//
+// Variables are looked up in the Env passed to Eval, so one tree can be
+// evaluated with many bindings:
+//
+//	tree, err := expr.Parse("x < 0 ? -x : x")
+//	if err != nil {
+//		return err
+//	}
+//	v, err := expr.Eval(tree, expr.Env{"x": -3.0}) // 3
+package expr
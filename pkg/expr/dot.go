@@ -0,0 +1,131 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// toDOT adds a node and the edges to its operands to a Graphviz graph,
+// returning the id of the node it added
+type toDOT interface {
+	ToDOT(g *DOTGraph) int
+}
+
+// DOTGraph accumulates the statements of a Graphviz digraph. ToDOT methods
+// add their node to it with Leaf or Operator.
+type DOTGraph struct {
+	body  strings.Builder
+	nodes int
+}
+
+// Leaf adds a node labelled label and returns its id
+func (g *DOTGraph) Leaf(label string) int {
+	id := g.nodes
+	g.nodes++
+	fmt.Fprintf(&g.body, "  n%d [label=%s];\n", id, strconv.Quote(label))
+	return id
+}
+
+// Operator adds a node labelled op with an edge to each operand, in order,
+// and returns its id
+func (g *DOTGraph) Operator(op string, operands ...Node) int {
+	id := g.Leaf(op)
+	for _, operand := range operands {
+		fmt.Fprintf(&g.body, "  n%d -> n%d;\n", id, dot(g, operand))
+	}
+	return id
+}
+
+// dot adds a node that DOT has checked to g
+func dot(g *DOTGraph, e Node) int {
+	return e.(toDOT).ToDOT(g)
+}
+
+// DOT renders e as a Graphviz digraph, failing if any of its nodes doesn't
+// implement ToDOT
+func DOT(e Node) (string, error) {
+	if err := supports("dot", e, func(n Node) bool { _, ok := n.(toDOT); return ok }); err != nil {
+		return "", err
+	}
+	g := &DOTGraph{}
+	dot(g, e)
+	return "digraph expr {\n  node [shape=box];\n" + g.body.String() + "}", nil
+}
+
+func (c *Constant) ToDOT(g *DOTGraph) int {
+	return g.Leaf(c.ToString())
+}
+
+func (v *Variable) ToDOT(g *DOTGraph) int {
+	return g.Leaf(v.Name)
+}
+
+func (bp *BinaryPlus) ToDOT(g *DOTGraph) int {
+	return g.Operator("+", bp.Left, bp.Right)
+}
+
+func (bp *BinaryMinus) ToDOT(g *DOTGraph) int {
+	return g.Operator("-", bp.Left, bp.Right)
+}
+
+func (bp *BinaryMultiply) ToDOT(g *DOTGraph) int {
+	return g.Operator("*", bp.Left, bp.Right)
+}
+
+func (bp *BinaryDivide) ToDOT(g *DOTGraph) int {
+	return g.Operator("/", bp.Left, bp.Right)
+}
+
+func (bp *BinaryModulo) ToDOT(g *DOTGraph) int {
+	return g.Operator("%", bp.Left, bp.Right)
+}
+
+func (bp *BinaryPower) ToDOT(g *DOTGraph) int {
+	return g.Operator("^", bp.Left, bp.Right)
+}
+
+func (um *UnaryMinus) ToDOT(g *DOTGraph) int {
+	return g.Operator("neg", um.Operand)
+}
+
+func (c *Call) ToDOT(g *DOTGraph) int {
+	return g.Operator(c.Fn.Name+"()", c.Args...)
+}
+
+func (bp *BinaryLess) ToDOT(g *DOTGraph) int {
+	return g.Operator("<", bp.Left, bp.Right)
+}
+
+func (bp *BinaryLessEqual) ToDOT(g *DOTGraph) int {
+	return g.Operator("<=", bp.Left, bp.Right)
+}
+
+func (bp *BinaryEqual) ToDOT(g *DOTGraph) int {
+	return g.Operator("==", bp.Left, bp.Right)
+}
+
+func (bp *BinaryAnd) ToDOT(g *DOTGraph) int {
+	return g.Operator("&&", bp.Left, bp.Right)
+}
+
+func (bp *BinaryOr) ToDOT(g *DOTGraph) int {
+	return g.Operator("||", bp.Left, bp.Right)
+}
+
+func (un *UnaryNot) ToDOT(g *DOTGraph) int {
+	return g.Operator("!", un.Operand)
+}
+
+// ToDOT links the condition, then the two branches
+func (c *Conditional) ToDOT(g *DOTGraph) int {
+	return g.Operator("?:", c.Cond, c.Then, c.Otherwise)
+}
+
+func (u *UnitLiteral) ToDOT(g *DOTGraph) int {
+	return g.Leaf(u.Unit.Symbol)
+}
+
+func (c *Conversion) ToDOT(g *DOTGraph) int {
+	return g.Operator("to", c.Operand, c.Target)
+}
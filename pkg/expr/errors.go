@@ -0,0 +1,140 @@
+package expr
+
+import (
+	"fmt"
+)
+
+// DecodeError reports an invalid serialized tree, locating the offending
+// node by its path from the root such as "args[1].args[0]"
+type DecodeError struct {
+	Path string
+	Msg  string
+}
+
+func (e *DecodeError) Error() string {
+	if e.Path == "" {
+		return e.Msg
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Msg)
+}
+
+// DivisionByZeroError is returned when the divisor of Expr evaluates to zero
+type DivisionByZeroError struct {
+	Expr string
+}
+
+func (e *DivisionByZeroError) Error() string {
+	return fmt.Sprintf("division by zero in %s", e.Expr)
+}
+
+// UnsupportedError is returned when a node does not implement the operation
+// being applied to it
+type UnsupportedError struct {
+	Op   string
+	Node string
+	Expr string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("operation %s not supported on node type %s in %s", e.Op, e.Node, e.Expr)
+}
+
+// OverflowError is returned when finite operands produce an infinite result
+type OverflowError struct {
+	Expr string
+}
+
+func (e *OverflowError) Error() string {
+	return fmt.Sprintf("overflow in %s", e.Expr)
+}
+
+// DomainError is returned when Expr has no real-valued result, e.g. (-8 ^ 0.5)
+type DomainError struct {
+	Expr string
+}
+
+func (e *DomainError) Error() string {
+	return fmt.Sprintf("result undefined in %s", e.Expr)
+}
+
+// InexactError is returned by the rational evaluator when Expr has no exact
+// rational value, e.g. (2 ^ 0.5)
+type InexactError struct {
+	Expr string
+}
+
+func (e *InexactError) Error() string {
+	return fmt.Sprintf("no exact rational result for %s", e.Expr)
+}
+
+// UndefinedError is returned when a variable is not bound at evaluation time
+type UndefinedError struct {
+	Name string
+}
+
+func (e *UndefinedError) Error() string {
+	return fmt.Sprintf("undefined variable %q", e.Name)
+}
+
+// unsupported builds an UnsupportedError for applying op to e
+func unsupported(op string, e Node) error {
+	return &UnsupportedError{Op: op, Node: fmt.Sprintf("%T", e), Expr: String(e)}
+}
+
+// supports returns an UnsupportedError for op on the first node of e that
+// implements reports false for, so that renderers can fail before writing
+// anything
+func supports(op string, e Node, implements func(Node) bool) error {
+	var err error
+	Inspect(e, func(n Node) bool {
+		if n == nil || err != nil {
+			return false
+		}
+		if !implements(n) {
+			err = unsupported(op, n)
+		}
+		return err == nil
+	})
+	return err
+}
+
+// numberExpected builds the error for evaluating e as a number with op:
+// a TypeError for nodes that produce bools, an UnsupportedError otherwise
+func numberExpected(op string, e Node) error {
+	if _, ok := e.(boolEval); ok {
+		return &TypeError{Expr: String(e), Want: typeNumber, Got: typeBool}
+	}
+	return unsupported(op, e)
+}
+
+// String renders e with ToString, falling back to its Go type for nodes that
+// don't implement it so that error messages never panic
+func String(e Node) string {
+	if s, ok := e.(toString); ok {
+		return s.ToString()
+	}
+	return fmt.Sprintf("<%T>", e)
+}
+
+// TypeError is returned when Expr evaluates to a value of type Got where a
+// value of type Want is needed, e.g. a bool used as an operand of +
+type TypeError struct {
+	Expr string
+	Want string
+	Got  string
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("%s is a %s, expected a %s", e.Expr, e.Got, e.Want)
+}
+
+// DimensionError is returned when the units in Expr don't fit together,
+// e.g. (1 * m) + (1 * s)
+type DimensionError struct {
+	Expr string
+	Msg  string
+}
+
+func (e *DimensionError) Error() string {
+	return fmt.Sprintf("dimension mismatch in %s: %s", e.Expr, e.Msg)
+}
@@ -0,0 +1,46 @@
+package expr
+
+import (
+	"math/big"
+	"strconv"
+)
+
+// Eval evaluates n with the variables bound in env, returning a float64, a
+// Quantity when units are involved, or a bool for comparisons
+func Eval(n Node, env Env) (interface{}, error) {
+	return evaluateValue(n, env, quantities)
+}
+
+// EvalFloat evaluates a numeric tree without units, which is faster than
+// Eval and returns a plain float64
+func EvalFloat(n Node, env Env) (float64, error) {
+	return evaluate(n, env)
+}
+
+// EvalRat evaluates n exactly, returning a *big.Rat or a bool
+func EvalRat(n Node, env Env) (interface{}, error) {
+	return evaluateValue(n, env, ratNumbers)
+}
+
+// EvalBig evaluates n with prec bits of mantissa, returning a *big.Float or
+// a bool
+func EvalBig(n Node, env Env, prec uint) (interface{}, error) {
+	return evaluateValue(n, env, bigNumbers(prec))
+}
+
+// Format prints a value returned by Eval, EvalRat or EvalBig
+func Format(v interface{}) string {
+	switch v := v.(type) {
+	case *big.Rat:
+		return formatRat(v)
+	case *big.Float:
+		return formatBig(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case Quantity:
+		return v.String()
+	}
+	return "<invalid>"
+}
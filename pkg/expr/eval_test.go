@@ -0,0 +1,121 @@
+package expr
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	tests := map[string]struct {
+		src  string
+		want string // formatted with Format
+	}{
+		"unary minus":       {src: "2 * -3", want: "-6"},
+		"double minus":      {src: "--3", want: "3"},
+		"minus of power":    {src: "-2 ^ 2 == -4", want: "true"},
+		"power of negative": {src: "(-2) ^ 2", want: "4"},
+		"right power":       {src: "2 ^ 3 ^ 2", want: "512"},
+		"left power":        {src: "(2 ^ 3) ^ 2", want: "64"},
+		"negative exponent": {src: "2 ^ -1", want: "0.5"},
+		"modulo":            {src: "7 % 3", want: "1"},
+		"negative modulo":   {src: "-7 % 3", want: "-1"},
+		"fractional modulo": {src: "7.5 % 2", want: "1.5"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := Eval(e, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if Format(v) != tc.want {
+				t.Fatalf("expected: %s, got: %s", tc.want, Format(v))
+			}
+		})
+	}
+}
+
+func TestEvalRat(t *testing.T) {
+	tests := map[string]struct {
+		src  string
+		want string // formatted with Format
+		err  error
+	}{
+		"decimal sum":       {src: "0.1 + 0.2 == 3/10", want: "true"},
+		"fraction":          {src: "1/3 + 1/12", want: "5/12"},
+		"terminating":       {src: "1/8 - 1", want: "-0.875"},
+		"negative exponent": {src: "(2/3) ^ -2", want: "2.25"},
+		"large power":       {src: "10 ^ 40000 / 10 ^ 39999", want: "10"},
+		"modulo":            {src: "7.5 % -2", want: "1.5"},
+		"irrational":        {src: "2 ^ 0.5", err: &InexactError{Expr: "(2 ^ 0.5)"}},
+		"exponent limit":    {src: "1 + 2 ^ 70000", err: &OverflowError{Expr: "(2 ^ 70000)"}},
+		"size limit":        {src: "(2 ^ 100) ^ 2000", err: &OverflowError{Expr: "((2 ^ 100) ^ 2000)"}},
+		"division by zero":  {src: "1 / (1 - 1)", err: &DivisionByZeroError{Expr: "(1 / (1 - 1))"}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := EvalRat(e, nil)
+			if !reflect.DeepEqual(tc.err, err) {
+				t.Fatalf("expected: %v, got: %v", tc.err, err)
+			}
+			if err == nil && Format(v) != tc.want {
+				t.Fatalf("expected: %s, got: %s", tc.want, Format(v))
+			}
+		})
+	}
+}
+
+func TestEvalBig(t *testing.T) {
+	// huge is close to the largest exponent a big.Float can hold, so that
+	// dividing it by a small number overflows to infinity
+	huge := new(big.Float).SetMantExp(big.NewFloat(1), big.MaxExp-1)
+	small := new(big.Float).SetMantExp(big.NewFloat(1), -100)
+	env := Env{"huge": huge, "small": small, "inf": new(big.Float).SetInf(false)}
+
+	tests := map[string]struct {
+		src  string
+		prec uint
+		want string // formatted with Format
+		err  error
+	}{
+		"decimal sum":       {src: "0.1 + 0.2 == 0.3", prec: 256, want: "true"},
+		"third, 10 bits":    {src: "1/3", prec: 10, want: "0.33"},
+		"third, 64 bits":    {src: "1/3", prec: 64, want: "0.333333333333333333"},
+		"rounded away":      {src: "1 + 2^-20", prec: 10, want: "1"},
+		"kept":              {src: "1 + 2^-20", prec: 64, want: "1.00000095367431641"},
+		"square root":       {src: "2 ^ 0.5", prec: 64, want: "1.41421356237309505"},
+		"modulo":            {src: "-7.5 % 2", prec: 64, want: "-1.5"},
+		"exponent limit":    {src: "(2 ^ 32768) ^ 16384", prec: 256, err: &OverflowError{Expr: "((2 ^ 32768) ^ 16384)"}},
+		"tiny result":       {src: "(2 ^ -32768) ^ 8", prec: 256, err: &OverflowError{Expr: "((2 ^ -32768) ^ 8)"}},
+		"modulo overflow":   {src: "(2^32768)^32768 % (2^-32768)^32768", prec: 256, err: &OverflowError{Expr: "((2 ^ 32768) ^ 32768)"}},
+		"infinite quotient": {src: "huge % small", prec: 256, err: &OverflowError{Expr: "(huge % small)"}},
+		"infinite operand":  {src: "inf % 3", prec: 256, err: &OverflowError{Expr: "(inf % 3)"}},
+		"division by zero":  {src: "1 % (1 - 1)", prec: 64, err: &DivisionByZeroError{Expr: "(1 % (1 - 1))"}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := EvalBig(e, env, tc.prec)
+			if !reflect.DeepEqual(tc.err, err) {
+				t.Fatalf("expected: %v, got: %v", tc.err, err)
+			}
+			if err == nil && Format(v) != tc.want {
+				t.Fatalf("expected: %s, got: %s", tc.want, Format(v))
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Node is any node of an expression tree. Operations are interfaces that
+// node types implement separately, so new node types and new operations can
+// both be added without touching existing code.
+type Node interface{}
+
+// eval evaluates a node without error checking: division by zero gives Inf,
+// and booleans are 1 and 0
+type eval interface {
+	Eval(env Env) float64
+}
+
+// toString renders a node in fully parenthesized infix form
+type toString interface {
+	ToString() string
+}
+
+// Constant is a number literal
+type Constant struct {
+	Value float64
+	text  string // literal as written, if parsed; used by the exact evaluators
+}
+
+// BinaryPlus is Left + Right
+type BinaryPlus struct {
+	Left  Node
+	Right Node
+}
+
+// BinaryMinus is Left - Right
+type BinaryMinus struct {
+	Left  Node
+	Right Node
+}
+
+// BinaryMultiply is Left * Right
+type BinaryMultiply struct {
+	Left  Node
+	Right Node
+}
+
+// BinaryDivide is Left / Right
+type BinaryDivide struct {
+	Left  Node
+	Right Node
+}
+
+// BinaryModulo is Left % Right
+type BinaryModulo struct {
+	Left  Node
+	Right Node
+}
+
+// BinaryPower is Left ^ Right
+type BinaryPower struct {
+	Left  Node
+	Right Node
+}
+
+// UnaryMinus is -Operand
+type UnaryMinus struct {
+	Operand Node
+}
+
+func (c *Constant) Eval(env Env) float64 {
+	return c.Value
+}
+
+func (c *Constant) ToString() string {
+	return strconv.FormatFloat(c.Value, 'f', -1, 64)
+}
+
+func (bp *BinaryPlus) Eval(env Env) float64 {
+	return bp.Left.(eval).Eval(env) + bp.Right.(eval).Eval(env)
+}
+
+func (bp *BinaryPlus) ToString() string {
+	return fmt.Sprintf("(%s + %s)", String(bp.Left), String(bp.Right))
+}
+
+func (bp *BinaryMinus) Eval(env Env) float64 {
+	return bp.Left.(eval).Eval(env) - bp.Right.(eval).Eval(env)
+}
+
+func (bp *BinaryMinus) ToString() string {
+	return fmt.Sprintf("(%s - %s)", String(bp.Left), String(bp.Right))
+}
+
+func (bp *BinaryMultiply) Eval(env Env) float64 {
+	return bp.Left.(eval).Eval(env) * bp.Right.(eval).Eval(env)
+}
+
+func (bp *BinaryMultiply) ToString() string {
+	return fmt.Sprintf("(%s * %s)", String(bp.Left), String(bp.Right))
+}
+
+func (bp *BinaryDivide) Eval(env Env) float64 {
+	return bp.Left.(eval).Eval(env) / bp.Right.(eval).Eval(env)
+}
+
+func (bp *BinaryDivide) ToString() string {
+	return fmt.Sprintf("(%s / %s)", String(bp.Left), String(bp.Right))
+}
+
+func (bp *BinaryModulo) Eval(env Env) float64 {
+	return math.Mod(bp.Left.(eval).Eval(env), bp.Right.(eval).Eval(env))
+}
+
+func (bp *BinaryModulo) ToString() string {
+	return fmt.Sprintf("(%s %% %s)", String(bp.Left), String(bp.Right))
+}
+
+func (bp *BinaryPower) Eval(env Env) float64 {
+	return math.Pow(bp.Left.(eval).Eval(env), bp.Right.(eval).Eval(env))
+}
+
+// ToString parenthesizes a negated base, since -2 ^ 2 reads as -(2 ^ 2)
+func (bp *BinaryPower) ToString() string {
+	left := String(bp.Left)
+	switch n := bp.Left.(type) {
+	case *UnaryMinus:
+		left = "(" + left + ")"
+	case *Constant:
+		if math.Signbit(n.Value) {
+			left = "(" + left + ")"
+		}
+	}
+	return fmt.Sprintf("(%s ^ %s)", left, String(bp.Right))
+}
+
+func (um *UnaryMinus) Eval(env Env) float64 {
+	return -um.Operand.(eval).Eval(env)
+}
+
+// ToString doesn't wrap the operand since binary nodes parenthesize themselves
+func (um *UnaryMinus) ToString() string {
+	return "-" + String(um.Operand)
+}
@@ -0,0 +1,257 @@
+package expr_test
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shmsr/x/pkg/expr"
+)
+
+// square is a node type defined outside the package. It implements every
+// operation, mostly by lowering itself to x * x.
+type square struct {
+	x expr.Node
+}
+
+func (s *square) lower() expr.Node {
+	return &expr.BinaryMultiply{Left: s.x, Right: s.x}
+}
+
+func (s *square) ToString() string {
+	return "sq(" + expr.String(s.x) + ")"
+}
+
+func (s *square) Children() []expr.Node {
+	return []expr.Node{s.x}
+}
+
+func (s *square) Eval(env expr.Env) float64 {
+	v, _ := expr.EvalFloat(s.lower(), env)
+	return v
+}
+
+func (s *square) EvalChecked(env expr.Env) (float64, error) {
+	return expr.EvalFloat(s.lower(), env)
+}
+
+func (s *square) EvalQuantity(env expr.Env) (expr.Quantity, error) {
+	v, err := expr.Eval(s.lower(), env)
+	if err != nil {
+		return expr.Quantity{}, err
+	}
+	if q, ok := v.(expr.Quantity); ok {
+		return q, nil
+	}
+	return expr.Quantity{Value: v.(float64)}, nil
+}
+
+func (s *square) EvalRat(env expr.Env) (*big.Rat, error) {
+	v, err := expr.EvalRat(s.lower(), env)
+	if err != nil {
+		return nil, err
+	}
+	return v.(*big.Rat), nil
+}
+
+func (s *square) EvalBig(env expr.Env, prec uint) (*big.Float, error) {
+	v, err := expr.EvalBig(s.lower(), env, prec)
+	if err != nil {
+		return nil, err
+	}
+	return v.(*big.Float), nil
+}
+
+// The renderers check every node before rendering any, so rendering the
+// operand can't fail here
+func (s *square) ToRPN() string {
+	x, _ := expr.RPN(s.x)
+	return x + " sq"
+}
+
+func (s *square) ToLaTeX() string {
+	x, _ := expr.LaTeX(s.x)
+	return `\left(` + x + `\right)^{2}`
+}
+
+func (s *square) ToSExpr() string {
+	x, _ := expr.SExpr(s.x)
+	return "(sq " + x + ")"
+}
+
+func (s *square) ToDOT(g *expr.DOTGraph) int {
+	return g.Operator("sq", s.x)
+}
+
+func (s *square) ToJSON() (*expr.JSONNode, error) {
+	x, err := expr.JSONOf(s.x)
+	if err != nil {
+		return nil, err
+	}
+	return &expr.JSONNode{Kind: "sq", Args: []*expr.JSONNode{x}}, nil
+}
+
+func (s *square) Simplify() expr.Node {
+	return &square{expr.Simplify(s.x)}
+}
+
+func (s *square) Derive(x string) (expr.Node, error) {
+	return expr.Derive(s.lower(), x)
+}
+
+func (s *square) Compile(p *expr.Program) error {
+	return p.Compile(s.lower())
+}
+
+// positive is a node type defined outside the package whose value is a
+// bool. It implements only evaluation and compilation.
+type positive struct {
+	x expr.Node
+}
+
+func (p *positive) ToString() string {
+	return "positive(" + expr.String(p.x) + ")"
+}
+
+func (p *positive) Children() []expr.Node {
+	return []expr.Node{p.x}
+}
+
+func (p *positive) Eval(env expr.Env) float64 {
+	if v, _ := expr.EvalFloat(p.x, env); v > 0 {
+		return 1
+	}
+	return 0
+}
+
+// EvalBool evaluates the operand in the mode num belongs to
+func (p *positive) EvalBool(env expr.Env, num expr.Numeric) (bool, error) {
+	v, err := num(p.x, env)
+	if err != nil {
+		return false, err
+	}
+	switch v := v.(type) {
+	case float64:
+		return v > 0, nil
+	case expr.Quantity:
+		return v.Value > 0, nil
+	case *big.Rat:
+		return v.Sign() > 0, nil
+	case *big.Float:
+		return v.Sign() > 0, nil
+	}
+	return false, errors.New("unexpected value")
+}
+
+func (p *positive) CompileBool(prog *expr.Program) error {
+	return prog.CompileBool(&expr.BinaryLess{Left: &expr.Constant{Value: 0}, Right: p.x})
+}
+
+func TestExtension(t *testing.T) {
+	x := &expr.Variable{Name: "x"}
+	// sq(x - 1) + 1
+	tree := &expr.BinaryPlus{
+		Left:  &square{&expr.BinaryMinus{Left: x, Right: &expr.Constant{Value: 1}}},
+		Right: &expr.Constant{Value: 1},
+	}
+	// positive(x) ? sq(x - 1) + 1 : 0
+	cond := &expr.Conditional{Cond: &positive{x}, Then: tree, Otherwise: &expr.Constant{Value: 0}}
+	env := expr.Env{"x": 3.0}
+
+	render := func(f func(expr.Node) (string, error)) func() (string, error) {
+		return func() (string, error) { return f(tree) }
+	}
+	value := func(f func() (interface{}, error)) func() (string, error) {
+		return func() (string, error) {
+			v, err := f()
+			return expr.Format(v), err
+		}
+	}
+	tests := map[string]struct {
+		op   func() (string, error)
+		want string // the result, or a substring of it for DOT and JSON
+	}{
+		"String":    {op: func() (string, error) { return expr.String(tree), nil }, want: "(sq((x - 1)) + 1)"},
+		"RPN":       {op: render(expr.RPN), want: "x 1 - sq 1 +"},
+		"LaTeX":     {op: render(expr.LaTeX), want: `\left(x - 1\right)^{2} + 1`},
+		"SExpr":     {op: render(expr.SExpr), want: "(+ (sq (- x 1)) 1)"},
+		"DOT":       {op: render(expr.DOT), want: `n1 [label="sq"];`},
+		"JSON":      {op: render(expr.JSON), want: `"kind": "sq"`},
+		"Eval":      {op: value(func() (interface{}, error) { return expr.Eval(tree, env) }), want: "5"},
+		"EvalFloat": {op: value(func() (interface{}, error) { return expr.EvalFloat(tree, env) }), want: "5"},
+		"EvalRat":   {op: value(func() (interface{}, error) { return expr.EvalRat(tree, expr.Env{"x": 0.5}) }), want: "1.25"},
+		"EvalBig":   {op: value(func() (interface{}, error) { return expr.EvalBig(tree, env, 64) }), want: "5"},
+		"Simplify":  {op: func() (string, error) { return expr.String(expr.Simplify(tree)), nil }, want: "(1 + sq((x - 1)))"},
+		"Variables": {op: func() (string, error) { return strings.Join(expr.Variables(tree), ","), nil }, want: "x"},
+		"Derive": {
+			op: func() (string, error) {
+				d, err := expr.Derive(tree, "x")
+				if err != nil {
+					return "", err
+				}
+				return value(func() (interface{}, error) { return expr.Eval(d, env) })()
+			},
+			want: "4",
+		},
+		"Compile": {
+			op: func() (string, error) {
+				p, err := expr.Compile(cond)
+				if err != nil {
+					return "", err
+				}
+				vars, err := p.Bind(env)
+				if err != nil {
+					return "", err
+				}
+				return value(func() (interface{}, error) { return expr.NewVM(p).Run(vars) })()
+			},
+			want: "5",
+		},
+		"bool in float mode": {op: value(func() (interface{}, error) { return expr.Eval(cond, expr.Env{"x": -1.0}) }), want: "0"},
+		"bool in rat mode":   {op: value(func() (interface{}, error) { return expr.EvalRat(cond, expr.Env{"x": 0.5}) }), want: "1.25"},
+		"bool in big mode":   {op: value(func() (interface{}, error) { return expr.EvalBig(cond, env, 64) }), want: "5"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := tc.op()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(got, tc.want) {
+				t.Fatalf("expected: %s, got: %s", tc.want, got)
+			}
+		})
+	}
+}
+
+// TestExtensionUnsupported checks that operations a node type doesn't
+// implement are reported rather than rendered or evaluated partially
+func TestExtensionUnsupported(t *testing.T) {
+	tree := &expr.BinaryPlus{Left: &expr.Constant{Value: 1}, Right: &positive{&expr.Variable{Name: "x"}}}
+	want := func(op string) error {
+		return &expr.UnsupportedError{Op: op, Node: "*expr_test.positive", Expr: "positive(x)"}
+	}
+
+	tests := map[string]struct {
+		op  func() error
+		err error
+	}{
+		"RPN":    {op: func() error { _, err := expr.RPN(tree); return err }, err: want("rpn")},
+		"LaTeX":  {op: func() error { _, err := expr.LaTeX(tree); return err }, err: want("latex")},
+		"SExpr":  {op: func() error { _, err := expr.SExpr(tree); return err }, err: want("sexpr")},
+		"DOT":    {op: func() error { _, err := expr.DOT(tree); return err }, err: want("dot")},
+		"JSON":   {op: func() error { _, err := expr.JSON(tree); return err }, err: want("json")},
+		"Derive": {op: func() error { _, err := expr.Derive(tree, "x"); return err }, err: want("derive")},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := tc.op(); !reflect.DeepEqual(tc.err, err) {
+				t.Fatalf("expected: %v, got: %v", tc.err, err)
+			}
+		})
+	}
+}
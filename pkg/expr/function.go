@@ -0,0 +1,140 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Variadic marks a function without an upper bound on its argument count
+const Variadic = -1
+
+// Function is a named operation callable from expressions as name(args...)
+type Function struct {
+	Name    string
+	MinArgs int
+	MaxArgs int
+	Fn      func(args []float64) float64
+}
+
+// Arity describes the accepted argument count, e.g. "1", "2" or "at least 1"
+func (f *Function) Arity() string {
+	switch {
+	case f.MaxArgs == Variadic:
+		return fmt.Sprintf("at least %d", f.MinArgs)
+	case f.MinArgs == f.MaxArgs:
+		return fmt.Sprintf("%d", f.MinArgs)
+	}
+	return fmt.Sprintf("%d to %d", f.MinArgs, f.MaxArgs)
+}
+
+func (f *Function) Accepts(n int) bool {
+	return n >= f.MinArgs && (f.MaxArgs == Variadic || n <= f.MaxArgs)
+}
+
+// functions holds every function the parser can resolve. Registration may
+// run concurrently with parsing, so every access holds functionsMu.
+var (
+	functionsMu sync.RWMutex
+	functions   = map[string]*Function{}
+)
+
+// RegisterFunction makes fn callable from expressions under name, replacing
+// any function already registered with that name; maxArgs may be Variadic
+func RegisterFunction(name string, minArgs, maxArgs int, fn func(args []float64) float64) {
+	functionsMu.Lock()
+	defer functionsMu.Unlock()
+	functions[name] = &Function{Name: name, MinArgs: minArgs, MaxArgs: maxArgs, Fn: fn}
+}
+
+// LookupFunction returns the function registered under name
+func LookupFunction(name string) (*Function, bool) {
+	functionsMu.RLock()
+	defer functionsMu.RUnlock()
+	f, ok := functions[name]
+	return f, ok
+}
+
+// FunctionNames returns the registered function names in sorted order
+func FunctionNames() []string {
+	functionsMu.RLock()
+	defer functionsMu.RUnlock()
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func unary(fn func(float64) float64) func([]float64) float64 {
+	return func(args []float64) float64 { return fn(args[0]) }
+}
+
+func binary(fn func(float64, float64) float64) func([]float64) float64 {
+	return func(args []float64) float64 { return fn(args[0], args[1]) }
+}
+
+func init() {
+	for name, fn := range map[string]func(float64) float64{
+		"abs":   math.Abs,
+		"sqrt":  math.Sqrt,
+		"cbrt":  math.Cbrt,
+		"exp":   math.Exp,
+		"log":   math.Log,
+		"log2":  math.Log2,
+		"log10": math.Log10,
+		"sin":   math.Sin,
+		"cos":   math.Cos,
+		"tan":   math.Tan,
+		"asin":  math.Asin,
+		"acos":  math.Acos,
+		"atan":  math.Atan,
+		"floor": math.Floor,
+		"ceil":  math.Ceil,
+		"round": math.Round,
+		"trunc": math.Trunc,
+	} {
+		RegisterFunction(name, 1, 1, unary(fn))
+	}
+	RegisterFunction("atan2", 2, 2, binary(math.Atan2))
+	RegisterFunction("hypot", 2, 2, binary(math.Hypot))
+	RegisterFunction("min", 1, Variadic, func(args []float64) float64 {
+		m := args[0]
+		for _, v := range args[1:] {
+			m = math.Min(m, v)
+		}
+		return m
+	})
+	RegisterFunction("max", 1, Variadic, func(args []float64) float64 {
+		m := args[0]
+		for _, v := range args[1:] {
+			m = math.Max(m, v)
+		}
+		return m
+	})
+}
+
+// Call applies a registered function to its argument expressions
+type Call struct {
+	Fn   *Function
+	Args []Node
+}
+
+func (c *Call) Eval(env Env) float64 {
+	args := make([]float64, len(c.Args))
+	for i, arg := range c.Args {
+		args[i] = arg.(eval).Eval(env)
+	}
+	return c.Fn.Fn(args)
+}
+
+func (c *Call) ToString() string {
+	args := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		args[i] = String(arg)
+	}
+	return fmt.Sprintf("%s(%s)", c.Fn.Name, strings.Join(args, ", "))
+}
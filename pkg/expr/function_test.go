@@ -0,0 +1,98 @@
+package expr
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestFunctions(t *testing.T) {
+	tests := map[string]struct {
+		src  string
+		want string // formatted with Format
+		err  error
+	}{
+		"sqrt":            {src: "sqrt(16)", want: "4"},
+		"abs":             {src: "abs(-2.5)", want: "2.5"},
+		"nested":          {src: "sqrt(abs(-9)) + 1", want: "4"},
+		"expression args": {src: "hypot(1 + 2, 2 * 2)", want: "5"},
+		"atan2":           {src: "4 * atan2(1, 1)", want: "3.141592653589793"},
+		"round":           {src: "round(2.5)", want: "3"},
+		"floor":           {src: "floor(-1.5)", want: "-2"},
+		"log10":           {src: "log10(1000)", want: "3"},
+		"min":             {src: "min(3, 1, 2)", want: "1"},
+		"max of one":      {src: "max(7)", want: "7"},
+		"domain":          {src: "1 + sqrt(-1)", err: &DomainError{Expr: "sqrt(-1)"}},
+		"pole":            {src: "log(0)", err: &OverflowError{Expr: "log(0)"}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := Eval(e, nil)
+			if !reflect.DeepEqual(tc.err, err) {
+				t.Fatalf("expected: %v, got: %v", tc.err, err)
+			}
+			if err == nil && Format(v) != tc.want {
+				t.Fatalf("expected: %s, got: %s", tc.want, Format(v))
+			}
+		})
+	}
+}
+
+func TestFunctionErrors(t *testing.T) {
+	tests := map[string]struct {
+		src string
+		err error
+	}{
+		"too few":          {src: "1 + sqrt()", err: &SyntaxError{Pos: 5, Msg: "sqrt expects 1 argument(s), got 0"}},
+		"too many":         {src: "sqrt(1, 2)", err: &SyntaxError{Pos: 1, Msg: "sqrt expects 1 argument(s), got 2"}},
+		"binary":           {src: "atan2(1)", err: &SyntaxError{Pos: 1, Msg: "atan2 expects 2 argument(s), got 1"}},
+		"variadic":         {src: "min()", err: &SyntaxError{Pos: 1, Msg: "min expects at least 1 argument(s), got 0"}},
+		"unknown function": {src: "2 * f(1)", err: &SyntaxError{Pos: 5, Msg: `unknown function "f"`}},
+		"missing comma":    {src: "max(1 2)", err: &SyntaxError{Pos: 7, Msg: `expected ')', found "2"`}},
+		"unclosed call":    {src: "max(1, 2", err: &SyntaxError{Pos: 9, Msg: "expected ')', found end of input"}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse(tc.src); !reflect.DeepEqual(tc.err, err) {
+				t.Fatalf("expected: %v, got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestRegisterFunction(t *testing.T) {
+	RegisterFunction("testclamp", 3, 3, func(args []float64) float64 {
+		if args[0] < args[1] {
+			return args[1]
+		}
+		if args[0] > args[2] {
+			return args[2]
+		}
+		return args[0]
+	})
+
+	fn, ok := LookupFunction("testclamp")
+	if !ok {
+		t.Fatal("testclamp is not registered")
+	}
+	if fn.Arity() != "3" || !fn.Accepts(3) || fn.Accepts(2) {
+		t.Fatalf("unexpected arity: %s", fn.Arity())
+	}
+	names := FunctionNames()
+	if i := sort.SearchStrings(names, "testclamp"); !sort.StringsAreSorted(names) || i == len(names) || names[i] != "testclamp" {
+		t.Fatalf("expected testclamp in sorted names, got: %v", names)
+	}
+	e, err := Parse("testclamp(x, 0, 1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := EvalFloat(e, Env{"x": 5.0}); err != nil || v != 1 {
+		t.Fatalf("expected: 1, got: %v (%v)", v, err)
+	}
+}
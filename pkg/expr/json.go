@@ -0,0 +1,258 @@
+package expr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// JSONNode is the JSON form of a node. Kind is "constant" (with Value),
+// "variable" or "unit" (with Name), "call" (with Name and Args), "neg",
+// "!", "?:", "to" or one of the binary operators "+", "-", "*", "/", "%",
+// "^", "<", "<=", "==", "&&", "||" (with Args).
+type JSONNode struct {
+	Kind  string      `json:"kind"`
+	Value *float64    `json:"value,omitempty"`
+	Name  string      `json:"name,omitempty"`
+	Args  []*JSONNode `json:"args,omitempty"`
+}
+
+// toJSON converts a node to its JSON form
+type toJSON interface {
+	ToJSON() (*JSONNode, error)
+}
+
+// JSONOf converts e to its JSON form; ToJSON methods call it for their
+// operands
+func JSONOf(e Node) (*JSONNode, error) {
+	j, ok := e.(toJSON)
+	if !ok {
+		return nil, unsupported("json", e)
+	}
+	return j.ToJSON()
+}
+
+// jsonOperator converts the operands of an operator node named kind
+func jsonOperator(kind string, operands ...Node) (*JSONNode, error) {
+	n := &JSONNode{Kind: kind, Args: make([]*JSONNode, len(operands))}
+	for i, operand := range operands {
+		arg, err := JSONOf(operand)
+		if err != nil {
+			return nil, err
+		}
+		n.Args[i] = arg
+	}
+	return n, nil
+}
+
+// JSON renders e as an indented JSON document
+func JSON(e Node) (string, error) {
+	n, err := JSONOf(e)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.MarshalIndent(n, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// ToJSON rejects infinities and NaN, which JSON numbers can't represent
+func (c *Constant) ToJSON() (*JSONNode, error) {
+	if math.IsInf(c.Value, 0) || math.IsNaN(c.Value) {
+		return nil, &DomainError{Expr: c.ToString()}
+	}
+	v := c.Value
+	return &JSONNode{Kind: "constant", Value: &v}, nil
+}
+
+func (v *Variable) ToJSON() (*JSONNode, error) {
+	return &JSONNode{Kind: "variable", Name: v.Name}, nil
+}
+
+func (bp *BinaryPlus) ToJSON() (*JSONNode, error) {
+	return jsonOperator("+", bp.Left, bp.Right)
+}
+
+func (bp *BinaryMinus) ToJSON() (*JSONNode, error) {
+	return jsonOperator("-", bp.Left, bp.Right)
+}
+
+func (bp *BinaryMultiply) ToJSON() (*JSONNode, error) {
+	return jsonOperator("*", bp.Left, bp.Right)
+}
+
+func (bp *BinaryDivide) ToJSON() (*JSONNode, error) {
+	return jsonOperator("/", bp.Left, bp.Right)
+}
+
+func (bp *BinaryModulo) ToJSON() (*JSONNode, error) {
+	return jsonOperator("%", bp.Left, bp.Right)
+}
+
+func (bp *BinaryPower) ToJSON() (*JSONNode, error) {
+	return jsonOperator("^", bp.Left, bp.Right)
+}
+
+func (um *UnaryMinus) ToJSON() (*JSONNode, error) {
+	return jsonOperator("neg", um.Operand)
+}
+
+func (c *Call) ToJSON() (*JSONNode, error) {
+	n, err := jsonOperator("call", c.Args...)
+	if err != nil {
+		return nil, err
+	}
+	n.Name = c.Fn.Name
+	return n, nil
+}
+
+func (bp *BinaryLess) ToJSON() (*JSONNode, error) {
+	return jsonOperator("<", bp.Left, bp.Right)
+}
+
+func (bp *BinaryLessEqual) ToJSON() (*JSONNode, error) {
+	return jsonOperator("<=", bp.Left, bp.Right)
+}
+
+func (bp *BinaryEqual) ToJSON() (*JSONNode, error) {
+	return jsonOperator("==", bp.Left, bp.Right)
+}
+
+func (bp *BinaryAnd) ToJSON() (*JSONNode, error) {
+	return jsonOperator("&&", bp.Left, bp.Right)
+}
+
+func (bp *BinaryOr) ToJSON() (*JSONNode, error) {
+	return jsonOperator("||", bp.Left, bp.Right)
+}
+
+func (un *UnaryNot) ToJSON() (*JSONNode, error) {
+	return jsonOperator("!", un.Operand)
+}
+
+// ToJSON lists the condition first, then the two branches
+func (c *Conditional) ToJSON() (*JSONNode, error) {
+	return jsonOperator("?:", c.Cond, c.Then, c.Otherwise)
+}
+
+func (u *UnitLiteral) ToJSON() (*JSONNode, error) {
+	return &JSONNode{Kind: "unit", Name: u.Unit.Symbol}, nil
+}
+
+func (c *Conversion) ToJSON() (*JSONNode, error) {
+	return jsonOperator("to", c.Operand, c.Target)
+}
+
+// DecodeJSON rebuilds a tree from the document produced by JSON
+func DecodeJSON(data []byte) (Node, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var n JSONNode
+	if err := dec.Decode(&n); err != nil {
+		return nil, &DecodeError{Msg: err.Error()}
+	}
+	if dec.More() {
+		return nil, &DecodeError{Msg: "unexpected data after the root node"}
+	}
+	return n.build("")
+}
+
+// build validates n and converts it to a node; path locates n for errors
+func (n *JSONNode) build(path string) (Node, error) {
+	fail := func(format string, args ...interface{}) error {
+		return &DecodeError{Path: path, Msg: fmt.Sprintf(format, args...)}
+	}
+
+	switch n.Kind {
+	case "constant":
+		if n.Value == nil || n.Name != "" || n.Args != nil {
+			return nil, fail("constant needs a value and nothing else")
+		}
+		// The shortest decimal form stands in for the literal, so that the
+		// exact evaluators read 0.1 as 1/10
+		return &Constant{Value: *n.Value, text: strconv.FormatFloat(*n.Value, 'g', -1, 64)}, nil
+	case "variable":
+		if !isIdentifier(n.Name) || n.Value != nil || n.Args != nil {
+			return nil, fail("variable needs a valid name and nothing else")
+		}
+		return &Variable{Name: n.Name}, nil
+	case "unit":
+		if n.Value != nil || n.Args != nil {
+			return nil, fail("unit needs a symbol and nothing else")
+		}
+		u, ok := LookupUnit(n.Name)
+		if !ok {
+			return nil, fail("unknown unit %q", n.Name)
+		}
+		return &UnitLiteral{u}, nil
+	}
+
+	switch n.Kind {
+	case "neg", "!", "?:", "to", "call":
+	default:
+		if _, ok := binaryNodes[n.Kind]; !ok {
+			return nil, fail("unknown kind %q", n.Kind)
+		}
+	}
+	if n.Value != nil {
+		return nil, fail("%s takes no value", n.Kind)
+	}
+	args := make([]Node, len(n.Args))
+	for i, arg := range n.Args {
+		if arg == nil {
+			return nil, fail("args[%d] is null", i)
+		}
+		e, err := arg.build(fmt.Sprintf("%sargs[%d]", prefix(path), i))
+		if err != nil {
+			return nil, err
+		}
+		args[i] = e
+	}
+
+	if build, ok := binaryNodes[n.Kind]; ok {
+		if n.Name != "" || len(args) != 2 {
+			return nil, fail("%s needs exactly 2 args and no name", n.Kind)
+		}
+		return build(args[0], args[1]), nil
+	}
+	switch n.Kind {
+	case "neg", "!":
+		if n.Name != "" || len(args) != 1 {
+			return nil, fail("%s needs exactly 1 arg and no name", n.Kind)
+		}
+		if n.Kind == "!" {
+			return &UnaryNot{args[0]}, nil
+		}
+		return &UnaryMinus{args[0]}, nil
+	case "?:":
+		if n.Name != "" || len(args) != 3 {
+			return nil, fail("?: needs exactly 3 args and no name")
+		}
+		return &Conditional{args[0], args[1], args[2]}, nil
+	case "to":
+		if n.Name != "" || len(args) != 2 {
+			return nil, fail("to needs exactly 2 args and no name")
+		}
+		return &Conversion{args[0], args[1]}, nil
+	}
+	fn, ok := LookupFunction(n.Name)
+	if !ok {
+		return nil, fail("unknown function %q", n.Name)
+	}
+	if !fn.Accepts(len(args)) {
+		return nil, fail("%s expects %s argument(s), got %d", fn.Name, fn.Arity(), len(args))
+	}
+	return &Call{Fn: fn, Args: args}, nil
+}
+
+// prefix turns a non-empty path into the prefix of a child's path
+func prefix(path string) string {
+	if path == "" {
+		return ""
+	}
+	return path + "."
+}
@@ -0,0 +1,225 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// toLaTeX renders a node as LaTeX math, parenthesizing only where the
+// precedence of the operators requires it
+type toLaTeX interface {
+	ToLaTeX() string
+}
+
+// Binding strength of rendered nodes; atoms include fractions and function
+// calls since they delimit their own operands
+const (
+	latexConversion = iota + 1
+	latexConditional
+	latexOr
+	latexAnd
+	latexComparison
+	latexAdditive
+	latexMultiplicative
+	latexUnary
+	latexPower
+	latexAtom
+)
+
+// LaTeX renders e with ToLaTeX, failing if any of its nodes doesn't
+// implement it
+func LaTeX(e Node) (string, error) {
+	if err := supports("latex", e, func(n Node) bool { _, ok := n.(toLaTeX); return ok }); err != nil {
+		return "", err
+	}
+	return latex(e), nil
+}
+
+// latex renders a node that LaTeX has checked
+func latex(e Node) string {
+	return e.(toLaTeX).ToLaTeX()
+}
+
+// latexPrec returns how tightly the rendering of e binds
+func latexPrec(e Node) int {
+	switch n := e.(type) {
+	case *Conversion:
+		return latexConversion
+	case *Conditional:
+		return latexConditional
+	case *BinaryOr:
+		return latexOr
+	case *BinaryAnd:
+		return latexAnd
+	case *BinaryLess, *BinaryLessEqual, *BinaryEqual:
+		return latexComparison
+	case *BinaryPlus, *BinaryMinus:
+		return latexAdditive
+	case *BinaryMultiply, *BinaryModulo:
+		return latexMultiplicative
+	case *UnaryMinus, *UnaryNot:
+		return latexUnary
+	case *BinaryPower:
+		return latexPower
+	case *Constant:
+		if n.Value < 0 {
+			return latexUnary
+		}
+	}
+	return latexAtom
+}
+
+// latexOperand renders e, wrapped in parentheses unless it binds more
+// tightly than min
+func latexOperand(e Node, min int) string {
+	if latexPrec(e) <= min {
+		return `\left(` + latex(e) + `\right)`
+	}
+	return latex(e)
+}
+
+// latexRight renders the right operand of an infix operator, which also
+// needs parentheses when negated: a + \left(-b\right)
+func latexRight(e Node, min int) string {
+	if latexPrec(e) == latexUnary {
+		min = latexUnary
+	}
+	return latexOperand(e, min)
+}
+
+// ToLaTeX writes exponents as powers of ten, e.g. 1.5 \times 10^{-7}
+func (c *Constant) ToLaTeX() string {
+	s := strconv.FormatFloat(c.Value, 'g', -1, 64)
+	i := strings.IndexByte(s, 'e')
+	if i < 0 {
+		return s
+	}
+	exp, _ := strconv.Atoi(s[i+1:])
+	return fmt.Sprintf(`%s \times 10^{%d}`, s[:i], exp)
+}
+
+// ToLaTeX sets multi-letter names upright so they don't read as a product
+func (v *Variable) ToLaTeX() string {
+	name := strings.ReplaceAll(v.Name, "_", `\_`)
+	if utf8.RuneCountInString(v.Name) > 1 {
+		return `\mathrm{` + name + `}`
+	}
+	return name
+}
+
+func (bp *BinaryPlus) ToLaTeX() string {
+	return fmt.Sprintf("%s + %s", latexOperand(bp.Left, latexComparison), latexRight(bp.Right, latexComparison))
+}
+
+// ToLaTeX parenthesizes an additive right operand: a - (b + c)
+func (bp *BinaryMinus) ToLaTeX() string {
+	return fmt.Sprintf("%s - %s", latexOperand(bp.Left, latexComparison), latexRight(bp.Right, latexAdditive))
+}
+
+func (bp *BinaryMultiply) ToLaTeX() string {
+	right := latexRight(bp.Right, latexMultiplicative)
+	if _, ok := bp.Right.(*BinaryMultiply); ok {
+		right = latex(bp.Right)
+	}
+	return fmt.Sprintf(`%s \cdot %s`, latexOperand(bp.Left, latexAdditive), right)
+}
+
+func (bp *BinaryDivide) ToLaTeX() string {
+	return fmt.Sprintf(`\frac{%s}{%s}`, latex(bp.Left), latex(bp.Right))
+}
+
+func (bp *BinaryModulo) ToLaTeX() string {
+	return fmt.Sprintf(`%s \bmod %s`, latexOperand(bp.Left, latexAdditive), latexRight(bp.Right, latexMultiplicative))
+}
+
+// ToLaTeX parenthesizes any base that isn't an atom, including powers
+// since ^ is right-associative: (a^b)^c
+func (bp *BinaryPower) ToLaTeX() string {
+	return fmt.Sprintf("%s^{%s}", latexOperand(bp.Left, latexPower), latex(bp.Right))
+}
+
+func (um *UnaryMinus) ToLaTeX() string {
+	return "-" + latexOperand(um.Operand, latexUnary)
+}
+
+// latexFunctions names the functions LaTeX has a dedicated operator for
+var latexFunctions = map[string]string{
+	"exp":  `\exp`,
+	"log":  `\ln`,
+	"sin":  `\sin`,
+	"cos":  `\cos`,
+	"tan":  `\tan`,
+	"asin": `\arcsin`,
+	"acos": `\arccos`,
+	"atan": `\arctan`,
+	"min":  `\min`,
+	"max":  `\max`,
+}
+
+func (c *Call) ToLaTeX() string {
+	args := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		args[i] = latex(arg)
+	}
+	switch c.Fn.Name {
+	case "sqrt":
+		return `\sqrt{` + args[0] + `}`
+	case "cbrt":
+		return `\sqrt[3]{` + args[0] + `}`
+	case "abs":
+		return `\left|` + args[0] + `\right|`
+	case "floor":
+		return `\left\lfloor ` + args[0] + ` \right\rfloor`
+	case "ceil":
+		return `\left\lceil ` + args[0] + ` \right\rceil`
+	case "log2":
+		return `\log_{2}\left(` + args[0] + `\right)`
+	case "log10":
+		return `\log_{10}\left(` + args[0] + `\right)`
+	}
+	name, ok := latexFunctions[c.Fn.Name]
+	if !ok {
+		name = `\operatorname{` + strings.ReplaceAll(c.Fn.Name, "_", `\_`) + `}`
+	}
+	return name + `\left(` + strings.Join(args, ", ") + `\right)`
+}
+
+func (bp *BinaryLess) ToLaTeX() string {
+	return fmt.Sprintf("%s < %s", latexOperand(bp.Left, latexComparison), latexOperand(bp.Right, latexComparison))
+}
+
+func (bp *BinaryLessEqual) ToLaTeX() string {
+	return fmt.Sprintf(`%s \le %s`, latexOperand(bp.Left, latexComparison), latexOperand(bp.Right, latexComparison))
+}
+
+func (bp *BinaryEqual) ToLaTeX() string {
+	return fmt.Sprintf("%s = %s", latexOperand(bp.Left, latexComparison), latexOperand(bp.Right, latexComparison))
+}
+
+func (bp *BinaryAnd) ToLaTeX() string {
+	return fmt.Sprintf(`%s \land %s`, latexOperand(bp.Left, latexOr), latexOperand(bp.Right, latexAnd))
+}
+
+func (bp *BinaryOr) ToLaTeX() string {
+	return fmt.Sprintf(`%s \lor %s`, latexOperand(bp.Left, latexConditional), latexOperand(bp.Right, latexOr))
+}
+
+func (un *UnaryNot) ToLaTeX() string {
+	return `\lnot ` + latexOperand(un.Operand, latexUnary)
+}
+
+// ToLaTeX writes a conditional as cases, which delimit their own operands
+func (c *Conditional) ToLaTeX() string {
+	return fmt.Sprintf(`\begin{cases} %s & \text{if } %s \\ %s & \text{otherwise} \end{cases}`,
+		latex(c.Then), latex(c.Cond), latex(c.Otherwise))
+}
+
+func (u *UnitLiteral) ToLaTeX() string {
+	return `\mathrm{` + strings.ReplaceAll(u.Unit.Symbol, "_", `\_`) + `}`
+}
+
+func (c *Conversion) ToLaTeX() string {
+	return fmt.Sprintf(`%s \to %s`, latexOperand(c.Operand, latexConversion), latex(c.Target))
+}
@@ -0,0 +1,187 @@
+package expr
+
+import (
+	"fmt"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokAssign
+	tokPlus
+	tokMinus
+	tokStar
+	tokSlash
+	tokPercent
+	tokCaret
+	tokLParen
+	tokRParen
+	tokComma
+	tokLess
+	tokLessEqual
+	tokEqual
+	tokAnd
+	tokOr
+	tokNot
+	tokQuestion
+	tokColon
+)
+
+var tokenNames = map[tokenKind]string{
+	tokEOF:       "end of input",
+	tokNumber:    "number",
+	tokIdent:     "identifier",
+	tokAssign:    "'='",
+	tokPlus:      "'+'",
+	tokMinus:     "'-'",
+	tokStar:      "'*'",
+	tokSlash:     "'/'",
+	tokPercent:   "'%'",
+	tokCaret:     "'^'",
+	tokLParen:    "'('",
+	tokRParen:    "')'",
+	tokComma:     "','",
+	tokLess:      "'<'",
+	tokLessEqual: "'<='",
+	tokEqual:     "'=='",
+	tokAnd:       "'&&'",
+	tokOr:        "'||'",
+	tokNot:       "'!'",
+	tokQuestion:  "'?'",
+	tokColon:     "':'",
+}
+
+func (k tokenKind) String() string {
+	if name, ok := tokenNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("token(%d)", int(k))
+}
+
+// token is a lexeme along with the column (1-based, in runes) it starts at
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var punctuation = map[rune]tokenKind{
+	'+': tokPlus,
+	'-': tokMinus,
+	'*': tokStar,
+	'/': tokSlash,
+	'%': tokPercent,
+	'^': tokCaret,
+	'(': tokLParen,
+	')': tokRParen,
+	',': tokComma,
+	'=': tokAssign,
+	'<': tokLess,
+	'!': tokNot,
+	'?': tokQuestion,
+	':': tokColon,
+}
+
+// digraphs are the two-character operators, matched before punctuation
+var digraphs = map[string]tokenKind{
+	"<=": tokLessEqual,
+	"==": tokEqual,
+	"&&": tokAnd,
+	"||": tokOr,
+}
+
+// tokenize splits src into tokens terminated by a tokEOF token
+func tokenize(src string) ([]token, error) {
+	var (
+		runes  = []rune(src)
+		tokens []token
+	)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case isDigit(r) || r == '.':
+			end, err := scanNumber(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: string(runes[i:end]), pos: i + 1})
+			i = end
+		case isIdentStart(r):
+			end := i + 1
+			for end < len(runes) && (isIdentStart(runes[end]) || unicode.IsDigit(runes[end])) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: string(runes[i:end]), pos: i + 1})
+			i = end
+		default:
+			if i+1 < len(runes) {
+				if kind, ok := digraphs[string(runes[i:i+2])]; ok {
+					tokens = append(tokens, token{kind: kind, text: string(runes[i : i+2]), pos: i + 1})
+					i += 2
+					continue
+				}
+			}
+			kind, ok := punctuation[r]
+			if !ok {
+				return nil, &SyntaxError{Pos: i + 1, Msg: fmt.Sprintf("unexpected character %q", r)}
+			}
+			tokens = append(tokens, token{kind: kind, text: string(r), pos: i + 1})
+			i++
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(runes) + 1}), nil
+}
+
+// scanNumber returns the index just past the number literal starting at
+// runes[start]: digits with an optional fraction and exponent
+func scanNumber(runes []rune, start int) (int, error) {
+	i := start
+	digits := 0
+	for ; i < len(runes) && isDigit(runes[i]); i++ {
+		digits++
+	}
+	if i < len(runes) && runes[i] == '.' {
+		for i++; i < len(runes) && isDigit(runes[i]); i++ {
+			digits++
+		}
+	}
+	if digits == 0 {
+		return 0, &SyntaxError{Pos: start + 1, Msg: "malformed number"}
+	}
+	if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+		j := i + 1
+		if j < len(runes) && (runes[j] == '+' || runes[j] == '-') {
+			j++
+		}
+		if j >= len(runes) || !isDigit(runes[j]) {
+			return 0, &SyntaxError{Pos: i + 1, Msg: "malformed exponent"}
+		}
+		for i = j; i < len(runes) && isDigit(runes[i]); i++ {
+		}
+	}
+	return i, nil
+}
+
+func isDigit(r rune) bool {
+	return '0' <= r && r <= '9'
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// isIdentifier reports whether s lexes as a single identifier
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		if !isIdentStart(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return s != ""
+}
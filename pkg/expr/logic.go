@@ -0,0 +1,358 @@
+package expr
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Names of the value types in TypeErrors
+const (
+	typeNumber = "number"
+	typeBool   = "bool"
+)
+
+// Numeric evaluates a numeric node in one of the evaluation modes, returning
+// a float64, Quantity, *big.Rat or *big.Float. Comparisons use it so that
+// they compare operands with the precision of the selected mode, and so can
+// EvalBool and EvalValue methods of node types defined outside the package.
+type Numeric func(e Node, env Env) (interface{}, error)
+
+func floatNumbers(e Node, env Env) (interface{}, error) {
+	v, err := evaluate(e, env)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func ratNumbers(e Node, env Env) (interface{}, error) {
+	v, err := evaluateRat(e, env)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func bigNumbers(prec uint) Numeric {
+	return func(e Node, env Env) (interface{}, error) {
+		v, err := evaluateBig(e, env, prec)
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
+
+// typeOf names the type of a value returned by evaluateValue
+func typeOf(v interface{}) string {
+	if _, ok := v.(bool); ok {
+		return typeBool
+	}
+	return typeNumber
+}
+
+// compareNumbers returns -1, 0 or +1 as a is less than, equal to or greater
+// than b; both come from the same numeric evaluator. Quantities compared in
+// e need the same dimension.
+func compareNumbers(e Node, a, b interface{}) (int, error) {
+	switch a := a.(type) {
+	case *big.Rat:
+		return a.Cmp(b.(*big.Rat)), nil
+	case *big.Float:
+		return a.Cmp(b.(*big.Float)), nil
+	}
+	l, r := asQuantity(a), asQuantity(b)
+	if err := sameDimension(e, l, r); err != nil {
+		return 0, err
+	}
+	switch {
+	case l.Value < r.Value:
+		return -1, nil
+	case l.Value > r.Value:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// boolEval evaluates a node whose value is a truth value, using num for any
+// numeric operands
+type boolEval interface {
+	EvalBool(env Env, num Numeric) (bool, error)
+}
+
+// evaluateBool evaluates e through boolEval
+func evaluateBool(e Node, env Env, num Numeric) (bool, error) {
+	be, ok := e.(boolEval)
+	if !ok {
+		if _, isNumber := e.(checkedEval); isNumber {
+			return false, &TypeError{Expr: String(e), Want: typeBool, Got: typeNumber}
+		}
+		return false, unsupported("bool", e)
+	}
+	return be.EvalBool(env, num)
+}
+
+// valueEval evaluates a node whose type is only known once it is
+// evaluated, such as a variable or a conditional
+type valueEval interface {
+	EvalValue(env Env, num Numeric) (interface{}, error)
+}
+
+// evaluateValue evaluates e to a number (through num) or a bool, whichever
+// it produces
+func evaluateValue(e Node, env Env, num Numeric) (interface{}, error) {
+	if ve, ok := e.(valueEval); ok {
+		return ve.EvalValue(env, num)
+	}
+	if _, ok := e.(boolEval); ok {
+		b, err := evaluateBool(e, env, num)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+	return num(e, env)
+}
+
+// comparisonOperands evaluates both sides of the comparison e as numbers
+func comparisonOperands(e, left, right Node, env Env, num Numeric) (int, error) {
+	l, err := num(left, env)
+	if err != nil {
+		return 0, err
+	}
+	r, err := num(right, env)
+	if err != nil {
+		return 0, err
+	}
+	return compareNumbers(e, l, r)
+}
+
+// truth converts b for the unchecked Eval, which has no bool type: true is
+// 1 and false is 0, and any nonzero operand counts as true
+func truth(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// BinaryLess is Left < Right
+type BinaryLess struct {
+	Left  Node
+	Right Node
+}
+
+// BinaryLessEqual is Left <= Right
+type BinaryLessEqual struct {
+	Left  Node
+	Right Node
+}
+
+// BinaryEqual compares two numbers or two bools
+type BinaryEqual struct {
+	Left  Node
+	Right Node
+}
+
+// BinaryAnd is Left && Right
+type BinaryAnd struct {
+	Left  Node
+	Right Node
+}
+
+// BinaryOr is Left || Right
+type BinaryOr struct {
+	Left  Node
+	Right Node
+}
+
+// UnaryNot is !Operand
+type UnaryNot struct {
+	Operand Node
+}
+
+// Conditional is "cond ? then : otherwise"; only the chosen branch is
+// evaluated, and its value may be a number or a bool
+type Conditional struct {
+	Cond      Node
+	Then      Node
+	Otherwise Node
+}
+
+func (v *Variable) EvalBool(env Env, num Numeric) (bool, error) {
+	switch value := env[v.Name].(type) {
+	case bool:
+		return value, nil
+	case nil:
+		return false, &UndefinedError{Name: v.Name}
+	}
+	return false, &TypeError{Expr: v.Name, Want: typeBool, Got: typeNumber}
+}
+
+func (v *Variable) EvalValue(env Env, num Numeric) (interface{}, error) {
+	if b, ok := env[v.Name].(bool); ok {
+		return b, nil
+	}
+	return num(v, env)
+}
+
+// notNumber is the error for evaluating v as a number when it isn't bound
+// to one
+func (v *Variable) notNumber(env Env) error {
+	if _, ok := env[v.Name].(bool); ok {
+		return &TypeError{Expr: v.Name, Want: typeNumber, Got: typeBool}
+	}
+	return &UndefinedError{Name: v.Name}
+}
+
+func (bp *BinaryLess) Eval(env Env) float64 {
+	return truth(bp.Left.(eval).Eval(env) < bp.Right.(eval).Eval(env))
+}
+
+func (bp *BinaryLess) ToString() string {
+	return fmt.Sprintf("(%s < %s)", String(bp.Left), String(bp.Right))
+}
+
+func (bp *BinaryLess) EvalBool(env Env, num Numeric) (bool, error) {
+	c, err := comparisonOperands(bp, bp.Left, bp.Right, env, num)
+	if err != nil {
+		return false, err
+	}
+	return c < 0, nil
+}
+
+func (bp *BinaryLessEqual) Eval(env Env) float64 {
+	return truth(bp.Left.(eval).Eval(env) <= bp.Right.(eval).Eval(env))
+}
+
+func (bp *BinaryLessEqual) ToString() string {
+	return fmt.Sprintf("(%s <= %s)", String(bp.Left), String(bp.Right))
+}
+
+func (bp *BinaryLessEqual) EvalBool(env Env, num Numeric) (bool, error) {
+	c, err := comparisonOperands(bp, bp.Left, bp.Right, env, num)
+	if err != nil {
+		return false, err
+	}
+	return c <= 0, nil
+}
+
+func (bp *BinaryEqual) Eval(env Env) float64 {
+	return truth(bp.Left.(eval).Eval(env) == bp.Right.(eval).Eval(env))
+}
+
+func (bp *BinaryEqual) ToString() string {
+	return fmt.Sprintf("(%s == %s)", String(bp.Left), String(bp.Right))
+}
+
+// EvalBool reports a TypeError when comparing a number with a bool
+func (bp *BinaryEqual) EvalBool(env Env, num Numeric) (bool, error) {
+	l, err := evaluateValue(bp.Left, env, num)
+	if err != nil {
+		return false, err
+	}
+	r, err := evaluateValue(bp.Right, env, num)
+	if err != nil {
+		return false, err
+	}
+	if typeOf(l) != typeOf(r) {
+		return false, &TypeError{Expr: String(bp.Right), Want: typeOf(l), Got: typeOf(r)}
+	}
+	if b, ok := l.(bool); ok {
+		return b == r.(bool), nil
+	}
+	c, err := compareNumbers(bp, l, r)
+	if err != nil {
+		return false, err
+	}
+	return c == 0, nil
+}
+
+func (bp *BinaryAnd) Eval(env Env) float64 {
+	return truth(bp.Left.(eval).Eval(env) != 0 && bp.Right.(eval).Eval(env) != 0)
+}
+
+func (bp *BinaryAnd) ToString() string {
+	return fmt.Sprintf("(%s && %s)", String(bp.Left), String(bp.Right))
+}
+
+// EvalBool skips the right operand when the left one is false
+func (bp *BinaryAnd) EvalBool(env Env, num Numeric) (bool, error) {
+	l, err := evaluateBool(bp.Left, env, num)
+	if err != nil || !l {
+		return false, err
+	}
+	return evaluateBool(bp.Right, env, num)
+}
+
+func (bp *BinaryOr) Eval(env Env) float64 {
+	return truth(bp.Left.(eval).Eval(env) != 0 || bp.Right.(eval).Eval(env) != 0)
+}
+
+func (bp *BinaryOr) ToString() string {
+	return fmt.Sprintf("(%s || %s)", String(bp.Left), String(bp.Right))
+}
+
+// EvalBool skips the right operand when the left one is true
+func (bp *BinaryOr) EvalBool(env Env, num Numeric) (bool, error) {
+	l, err := evaluateBool(bp.Left, env, num)
+	if err != nil || l {
+		return l, err
+	}
+	return evaluateBool(bp.Right, env, num)
+}
+
+func (un *UnaryNot) Eval(env Env) float64 {
+	return truth(un.Operand.(eval).Eval(env) == 0)
+}
+
+func (un *UnaryNot) ToString() string {
+	return "!" + String(un.Operand)
+}
+
+func (un *UnaryNot) EvalBool(env Env, num Numeric) (bool, error) {
+	v, err := evaluateBool(un.Operand, env, num)
+	if err != nil {
+		return false, err
+	}
+	return !v, nil
+}
+
+func (c *Conditional) Eval(env Env) float64 {
+	if c.Cond.(eval).Eval(env) != 0 {
+		return c.Then.(eval).Eval(env)
+	}
+	return c.Otherwise.(eval).Eval(env)
+}
+
+func (c *Conditional) ToString() string {
+	return fmt.Sprintf("(%s ? %s : %s)", String(c.Cond), String(c.Then), String(c.Otherwise))
+}
+
+// branch evaluates the condition and returns the branch it selects
+func (c *Conditional) branch(env Env, num Numeric) (Node, error) {
+	ok, err := evaluateBool(c.Cond, env, num)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return c.Then, nil
+	}
+	return c.Otherwise, nil
+}
+
+func (c *Conditional) EvalBool(env Env, num Numeric) (bool, error) {
+	b, err := c.branch(env, num)
+	if err != nil {
+		return false, err
+	}
+	return evaluateBool(b, env, num)
+}
+
+func (c *Conditional) EvalValue(env Env, num Numeric) (interface{}, error) {
+	b, err := c.branch(env, num)
+	if err != nil {
+		return nil, err
+	}
+	return evaluateValue(b, env, num)
+}
@@ -0,0 +1,63 @@
+package expr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEvalLogic(t *testing.T) {
+	env := Env{"x": 2.0, "y": 3.0, "yes": true, "no": false}
+	modes := map[string]func(Node) (interface{}, error){
+		"float": func(e Node) (interface{}, error) { return Eval(e, env) },
+		"rat":   func(e Node) (interface{}, error) { return EvalRat(e, env) },
+		"big":   func(e Node) (interface{}, error) { return EvalBig(e, env, 64) },
+	}
+
+	tests := map[string]struct {
+		src  string
+		want string // formatted with Format
+		err  error
+	}{
+		"less":               {src: "x < y", want: "true"},
+		"not less":           {src: "y < x", want: "false"},
+		"less equal":         {src: "x <= 2", want: "true"},
+		"equal":              {src: "x * 3 == y * 2", want: "true"},
+		"bool equal":         {src: "(x < y) == yes", want: "true"},
+		"and":                {src: "yes && x < y", want: "true"},
+		"or":                 {src: "no || y < x", want: "false"},
+		"not":                {src: "!(x < y)", want: "false"},
+		"not binds loosely":  {src: "!x < 1", want: "true"},
+		"bool variable":      {src: "!no", want: "true"},
+		"and short circuit":  {src: "no && 1 / 0 < 1", want: "false"},
+		"or short circuit":   {src: "yes || undefined", want: "true"},
+		"number branch":      {src: "x < y ? x : y", want: "2"},
+		"bool branch":        {src: "yes ? no : yes", want: "false"},
+		"untaken branch":     {src: "x < y ? 1 : 1 / 0", want: "1"},
+		"nested":             {src: "x == 1 ? 1 : y == 3 ? 3 : 0", want: "3"},
+		"taken branch error": {src: "y < x ? 1 : 1 / (x - 2)", err: &DivisionByZeroError{Expr: "(1 / (x - 2))"}},
+		"condition error":    {src: "1 / 0 < 1 ? 1 : 1", err: &DivisionByZeroError{Expr: "(1 / 0)"}},
+		"number as bool":     {src: "x && yes", err: &TypeError{Expr: "x", Want: typeBool, Got: typeNumber}},
+		"bool as number":     {src: "yes + 1", err: &TypeError{Expr: "yes", Want: typeNumber, Got: typeBool}},
+		"mixed equality":     {src: "x == yes", err: &TypeError{Expr: "yes", Want: typeNumber, Got: typeBool}},
+		"compared bool":      {src: "(x < y) < 1", err: &TypeError{Expr: "(x < y)", Want: typeNumber, Got: typeBool}},
+		"undefined":          {src: "!z", err: &UndefinedError{Name: "z"}},
+	}
+
+	for mode, eval := range modes {
+		for name, tc := range tests {
+			t.Run(mode+"/"+name, func(t *testing.T) {
+				e, err := Parse(tc.src)
+				if err != nil {
+					t.Fatal(err)
+				}
+				v, err := eval(e)
+				if !reflect.DeepEqual(tc.err, err) {
+					t.Fatalf("expected: %v, got: %v", tc.err, err)
+				}
+				if err == nil && Format(v) != tc.want {
+					t.Fatalf("expected: %s, got: %s", tc.want, Format(v))
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// SyntaxError reports malformed input along with the column it was found at
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Pos, e.Msg)
+}
+
+// binaryOp describes how an infix operator token binds and which node it builds
+type binaryOp struct {
+	prec       int
+	rightAssoc bool
+	build      func(left, right Node) Node
+}
+
+// Unary minus binds tighter than the multiplicative operators but looser
+// than '^', so "-2^2" is -(2^2). '!' applies to a whole comparison, so
+// "!x < 1" is !(x < 1).
+const (
+	precCompare = 3
+	precUnary   = 6
+)
+
+// binaryNodes builds the node for each binary operator symbol
+var binaryNodes = map[string]func(left, right Node) Node{
+	"+": func(l, r Node) Node { return &BinaryPlus{l, r} },
+	"-": func(l, r Node) Node { return &BinaryMinus{l, r} },
+	"*": func(l, r Node) Node { return &BinaryMultiply{l, r} },
+	"/": func(l, r Node) Node { return &BinaryDivide{l, r} },
+	"%": func(l, r Node) Node { return &BinaryModulo{l, r} },
+	"^": func(l, r Node) Node { return &BinaryPower{l, r} },
+
+	"<":  func(l, r Node) Node { return &BinaryLess{l, r} },
+	"<=": func(l, r Node) Node { return &BinaryLessEqual{l, r} },
+	"==": func(l, r Node) Node { return &BinaryEqual{l, r} },
+	"&&": func(l, r Node) Node { return &BinaryAnd{l, r} },
+	"||": func(l, r Node) Node { return &BinaryOr{l, r} },
+}
+
+var binaryOps = map[tokenKind]binaryOp{
+	tokOr:        {prec: 1, build: binaryNodes["||"]},
+	tokAnd:       {prec: 2, build: binaryNodes["&&"]},
+	tokLess:      {prec: precCompare, build: binaryNodes["<"]},
+	tokLessEqual: {prec: precCompare, build: binaryNodes["<="]},
+	tokEqual:     {prec: precCompare, build: binaryNodes["=="]},
+	tokPlus:      {prec: 4, build: binaryNodes["+"]},
+	tokMinus:     {prec: 4, build: binaryNodes["-"]},
+	tokStar:      {prec: 5, build: binaryNodes["*"]},
+	tokSlash:     {prec: 5, build: binaryNodes["/"]},
+	tokPercent:   {prec: 5, build: binaryNodes["%"]},
+	tokCaret:     {prec: 7, rightAssoc: true, build: binaryNodes["^"]},
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	env    Env
+	free   bool // accept identifiers that aren't bound in env
+}
+
+// Parse builds an expression tree out of an infix expression such as
+// "1 + 2 * (3 - 4)". Identifiers are variables, except right after a number
+// or "to", where they name units: "5 km", "x to m".
+func Parse(src string) (Node, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, free: true}
+	return p.parseAll()
+}
+
+// ParseEnv is like Parse but reports identifiers that aren't bound in env
+// as SyntaxErrors at their position, unless they name a unit. A bound
+// variable shadows a unit of the same name except as the target of "to", so
+// with m bound "5 m" is an error while "3 ft to m" converts to meters.
+func ParseEnv(src string, env Env) (Node, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, env: env}
+	return p.parseAll()
+}
+
+// ParseAssignment parses either "name = expression" or a bare expression,
+// in which case the returned name is empty. The expression is parsed as by
+// ParseEnv.
+func ParseAssignment(src string, env Env) (string, Node, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return "", nil, err
+	}
+	p := &parser{tokens: tokens, env: env}
+	if len(tokens) < 2 || tokens[0].kind != tokIdent || tokens[1].kind != tokAssign {
+		e, err := p.parseAll()
+		return "", e, err
+	}
+	name := p.next()
+	p.next()
+	e, err := p.parseAll()
+	return name.text, e, err
+}
+
+// parseAll parses a whole expression, rejecting any trailing tokens
+func (p *parser) parseAll() (Node, error) {
+	e, err := p.parseConversion()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.unexpected(tok)
+	}
+	return e, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) expect(kind tokenKind) (token, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("expected %v, found %s", kind, describe(tok))}
+	}
+	return tok, nil
+}
+
+func (p *parser) unexpected(tok token) error {
+	return &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %s", describe(tok))}
+}
+
+// parseConversion parses an expression followed by any number of
+// "to unit" conversions, which bind more loosely than anything else
+func (p *parser) parseConversion() (Node, error) {
+	e, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+	for tok := p.peek(); tok.kind == tokIdent && tok.text == "to"; tok = p.peek() {
+		p.next()
+		target, err := p.parseUnits()
+		if err != nil {
+			return nil, err
+		}
+		e = &Conversion{e, target}
+	}
+	return e, nil
+}
+
+// parseConditional parses "cond ? then : otherwise", which binds more
+// loosely than any operator and nests to the right, so "a ? b : c ? d : e"
+// is a ? b : (c ? d : e)
+func (p *parser) parseConditional() (Node, error) {
+	cond, err := p.parseExpr(1)
+	if err != nil || p.peek().kind != tokQuestion {
+		return cond, err
+	}
+	p.next()
+	then, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokColon); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+	return &Conditional{cond, then, otherwise}, nil
+}
+
+// parseExpr parses operands joined by operators binding at least as tightly
+// as minPrec (precedence climbing)
+func (p *parser) parseExpr(minPrec int) (Node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := binaryOps[p.peek().kind]
+		if !ok || op.prec < minPrec {
+			return left, nil
+		}
+		p.next()
+		nextPrec := op.prec + 1
+		if op.rightAssoc {
+			nextPrec = op.prec
+		}
+		right, err := p.parseExpr(nextPrec)
+		if err != nil {
+			return nil, err
+		}
+		left = op.build(left, right)
+	}
+}
+
+// parseOperand parses a primary expression preceded by any number of
+// unary signs, or a negated comparison
+func (p *parser) parseOperand() (Node, error) {
+	switch p.peek().kind {
+	case tokMinus:
+		p.next()
+		operand, err := p.parseExpr(precUnary)
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryMinus{operand}, nil
+	case tokPlus:
+		p.next()
+		return p.parseExpr(precUnary)
+	case tokNot:
+		p.next()
+		operand, err := p.parseExpr(precCompare)
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryNot{operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("invalid number %q", tok.text)}
+		}
+		c := &Constant{Value: v, text: tok.text}
+		if next := p.peek(); p.shadowsUnit(next) {
+			return nil, &SyntaxError{Pos: next.pos, Msg: fmt.Sprintf("variable %q shadows the unit of the same name", next.text)}
+		}
+		if !p.unitFollows() {
+			return c, nil
+		}
+		u, err := p.parseUnitTerm()
+		if err != nil {
+			return nil, err
+		}
+		return &BinaryMultiply{c, u}, nil
+	case tokIdent:
+		if p.peek().kind == tokLParen {
+			return p.parseCall(tok)
+		}
+		_, bound := p.env[tok.text]
+		if u, ok := LookupUnit(tok.text); ok && !bound && !p.free {
+			return &UnitLiteral{u}, nil
+		}
+		if !bound && !p.free {
+			return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("undefined variable %q", tok.text)}
+		}
+		return &Variable{Name: tok.text}, nil
+	case tokLParen:
+		e, err := p.parseConversion()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	return nil, p.unexpected(tok)
+}
+
+// unitFollows reports whether the next token is a unit written right after
+// a number, as in "5 km". Variables shadow units, and so do functions when
+// called: "5 min" is five minutes but "5 min(x, y)" is an error.
+func (p *parser) unitFollows() bool {
+	tok := p.peek()
+	if tok.kind != tokIdent || p.tokens[p.pos+1].kind == tokLParen {
+		return false
+	}
+	_, isUnit := LookupUnit(tok.text)
+	_, bound := p.env[tok.text]
+	return isUnit && !bound
+}
+
+// shadowsUnit reports whether tok names a unit hidden by a bound variable,
+// which can't follow a number: after "m = 2", "5 m" is an error rather than
+// ten or five meters
+func (p *parser) shadowsUnit(tok token) bool {
+	if tok.kind != tokIdent {
+		return false
+	}
+	_, isUnit := LookupUnit(tok.text)
+	_, bound := p.env[tok.text]
+	return isUnit && bound
+}
+
+// parseUnitTerm parses a unit literal with an optional exponent, as in
+// "m^2" or "s^-1"
+func (p *parser) parseUnitTerm() (Node, error) {
+	tok := p.next()
+	u, ok := LookupUnit(tok.text)
+	if tok.kind != tokIdent || !ok {
+		return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("expected a unit, found %s", describe(tok))}
+	}
+	var e Node = &UnitLiteral{u}
+	if p.peek().kind == tokCaret {
+		p.next()
+		exp, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		e = &BinaryPower{e, exp}
+	}
+	return e, nil
+}
+
+// parseUnits parses the target of a conversion: unit terms joined by '*'
+// and '/', such as "km/h" or "kg*m/s^2"
+func (p *parser) parseUnits() (Node, error) {
+	e, err := p.parseUnitTerm()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek().kind
+		if op != tokStar && op != tokSlash {
+			return e, nil
+		}
+		p.next()
+		term, err := p.parseUnitTerm()
+		if err != nil {
+			return nil, err
+		}
+		if op == tokStar {
+			e = &BinaryMultiply{e, term}
+		} else {
+			e = &BinaryDivide{e, term}
+		}
+	}
+}
+
+// parseCall parses the parenthesized argument list of a call to the
+// function named by tok
+func (p *parser) parseCall(name token) (Node, error) {
+	fn, ok := LookupFunction(name.text)
+	if !ok {
+		return nil, &SyntaxError{Pos: name.pos, Msg: fmt.Sprintf("unknown function %q", name.text)}
+	}
+	p.next()
+	var args []Node
+	if p.peek().kind != tokRParen {
+		for {
+			arg, err := p.parseConversion()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.peek().kind != tokComma {
+				break
+			}
+			p.next()
+		}
+	}
+	if _, err := p.expect(tokRParen); err != nil {
+		return nil, err
+	}
+	if !fn.Accepts(len(args)) {
+		return nil, &SyntaxError{
+			Pos: name.pos,
+			Msg: fmt.Sprintf("%s expects %s argument(s), got %d", fn.Name, fn.Arity(), len(args)),
+		}
+	}
+	return &Call{Fn: fn, Args: args}, nil
+}
+
+func describe(tok token) string {
+	if tok.kind == tokEOF || tok.text == "" {
+		return tok.kind.String()
+	}
+	return fmt.Sprintf("%q", tok.text)
+}
@@ -0,0 +1,115 @@
+package expr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		src  string
+		want string // ToString of the tree
+	}{
+		"times before plus":  {src: "1 + 2 * 3", want: "(1 + (2 * 3))"},
+		"divide before plus": {src: "1 - 6 / 3", want: "(1 - (6 / 3))"},
+		"parentheses":        {src: "(1 + 2) * 3", want: "((1 + 2) * 3)"},
+		"nested parentheses": {src: "((1))", want: "1"},
+		"minus is left":      {src: "1 - 2 - 3", want: "((1 - 2) - 3)"},
+		"divide is left":     {src: "1 / 2 / 3", want: "((1 / 2) / 3)"},
+		"mixed is left":      {src: "1 - 2 + 3", want: "((1 - 2) + 3)"},
+		"variables":          {src: "x * y + z", want: "((x * y) + z)"},
+		"exponent":           {src: "1.5e3 + .5", want: "(1500 + 0.5)"},
+		"no spaces":          {src: "1+2*x", want: "(1 + (2 * x))"},
+
+		"unary minus":          {src: "-x * 2", want: "(-x * 2)"},
+		"double minus":         {src: "--x", want: "--x"},
+		"unary plus":           {src: "+x", want: "x"},
+		"minus after operator": {src: "2 * -3", want: "(2 * -3)"},
+		"power before minus":   {src: "-2 ^ 2", want: "-(2 ^ 2)"},
+		"negated base":         {src: "(-2) ^ 2", want: "((-2) ^ 2)"},
+		"power is right":       {src: "2 ^ 3 ^ 2", want: "(2 ^ (3 ^ 2))"},
+		"negative exponent":    {src: "2 ^ -1", want: "(2 ^ -1)"},
+		"minus in exponent":    {src: "2 ^ -x ^ 2", want: "(2 ^ -(x ^ 2))"},
+		"modulo is left":       {src: "7 % 4 % 2", want: "((7 % 4) % 2)"},
+		"modulo before plus":   {src: "1 + 7 % 4 * 2", want: "(1 + ((7 % 4) * 2))"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := String(e); got != tc.want {
+				t.Fatalf("expected: %s, got: %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]struct {
+		src string
+		err error
+	}{
+		"unexpected token":    {src: "1 + * 2", err: &SyntaxError{Pos: 5, Msg: `unexpected "*"`}},
+		"missing operand":     {src: "1 +", err: &SyntaxError{Pos: 4, Msg: "unexpected end of input"}},
+		"unclosed paren":      {src: "(1 + 2", err: &SyntaxError{Pos: 7, Msg: "expected ')', found end of input"}},
+		"unopened paren":      {src: "1 + 2)", err: &SyntaxError{Pos: 6, Msg: `unexpected ")"`}},
+		"malformed number":    {src: "1 + . * 2", err: &SyntaxError{Pos: 5, Msg: "malformed number"}},
+		"malformed exponent":  {src: "2 * 1e+", err: &SyntaxError{Pos: 6, Msg: "malformed exponent"}},
+		"trailing input":      {src: "1 + 2 3", err: &SyntaxError{Pos: 7, Msg: `unexpected "3"`}},
+		"unexpected char":     {src: "1 # 2", err: &SyntaxError{Pos: 3, Msg: "unexpected character '#'"}},
+		"columns count runes": {src: "π + #", err: &SyntaxError{Pos: 5, Msg: "unexpected character '#'"}},
+		"empty":               {src: "", err: &SyntaxError{Pos: 1, Msg: "unexpected end of input"}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse(tc.src); !reflect.DeepEqual(tc.err, err) {
+				t.Fatalf("expected: %v, got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+// TestParseRoundTrip checks that ToString renders a tree Parse reads back
+// as the same tree
+func TestParseRoundTrip(t *testing.T) {
+	tests := []string{
+		"1 + 2 * 3",
+		"(1 + 2) * 3",
+		"1 - (2 - 3)",
+		"1 / (2 / 3) / 4",
+		"x * (y + z) - 0.25",
+		"-2 ^ 2",
+		"(-2) ^ 2",
+		"2 ^ 3 ^ 2",
+		"(2 ^ 3) ^ 2",
+		"-x % -(y - 1)",
+	}
+
+	for _, src := range tests {
+		t.Run(src, func(t *testing.T) {
+			e, err := Parse(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			again, err := Parse(String(e))
+			if err != nil {
+				t.Fatalf("%s: %v", String(e), err)
+			}
+			if !reflect.DeepEqual(e, again) {
+				t.Fatalf("expected: %s, got: %s", String(e), String(again))
+			}
+		})
+	}
+}
+
+func TestTokenNames(t *testing.T) {
+	for kind := tokEOF; kind <= tokColon; kind++ {
+		if _, ok := tokenNames[kind]; !ok {
+			t.Errorf("no name for %v", kind)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// quantityEval evaluates a node as a float64 with a dimension; it is the
+// float evaluation mode once units are involved
+type quantityEval interface {
+	EvalQuantity(env Env) (Quantity, error)
+}
+
+// evaluateQuantity evaluates e through quantityEval
+func evaluateQuantity(e Node, env Env) (Quantity, error) {
+	qe, ok := e.(quantityEval)
+	if !ok {
+		return Quantity{}, numberExpected("eval", e)
+	}
+	return qe.EvalQuantity(env)
+}
+
+// quantities is the Numeric evaluator of the float mode, returning plain
+// numbers as float64 and everything else as a quantity
+func quantities(e Node, env Env) (interface{}, error) {
+	q, err := evaluateQuantity(e, env)
+	if err != nil {
+		return nil, err
+	}
+	if q.scalar() {
+		return q.Value, nil
+	}
+	return q, nil
+}
+
+// asQuantity converts a value returned by quantities back to a quantity
+func asQuantity(v interface{}) Quantity {
+	if q, ok := v.(Quantity); ok {
+		return q
+	}
+	return Quantity{Value: v.(float64)}
+}
+
+// quantityOperands evaluates both sides of a binary node
+func quantityOperands(left, right Node, env Env) (Quantity, Quantity, error) {
+	l, err := evaluateQuantity(left, env)
+	if err != nil {
+		return Quantity{}, Quantity{}, err
+	}
+	r, err := evaluateQuantity(right, env)
+	if err != nil {
+		return Quantity{}, Quantity{}, err
+	}
+	return l, r, nil
+}
+
+// sameDimension checks that l and r can be added or compared in e
+func sameDimension(e Node, l, r Quantity) error {
+	if l.Dim != r.Dim {
+		return &DimensionError{Expr: String(e), Msg: fmt.Sprintf("%s vs %s", l.Dim, r.Dim)}
+	}
+	return nil
+}
+
+// finiteQuantity is finite for a result with dimension dim
+func finiteQuantity(e Node, v float64, dim Dimension) (Quantity, error) {
+	v, err := finite(e, v)
+	if err != nil {
+		return Quantity{}, err
+	}
+	return Quantity{Value: v, Dim: dim}, nil
+}
+
+func (c *Constant) EvalQuantity(env Env) (Quantity, error) {
+	return Quantity{Value: c.Value}, nil
+}
+
+func (v *Variable) EvalQuantity(env Env) (Quantity, error) {
+	switch value := env[v.Name].(type) {
+	case Quantity:
+		return value, nil
+	case float64:
+		return Quantity{Value: value}, nil
+	case *big.Rat, *big.Float:
+		f, _ := env.float(v.Name)
+		return Quantity{Value: f}, nil
+	}
+	return Quantity{}, v.notNumber(env)
+}
+
+func (u *UnitLiteral) EvalQuantity(env Env) (Quantity, error) {
+	return Quantity{Value: u.Unit.Scale, Dim: u.Unit.Dim}, nil
+}
+
+func (bp *BinaryPlus) EvalQuantity(env Env) (Quantity, error) {
+	l, r, err := quantityOperands(bp.Left, bp.Right, env)
+	if err != nil {
+		return Quantity{}, err
+	}
+	if err := sameDimension(bp, l, r); err != nil {
+		return Quantity{}, err
+	}
+	return finiteQuantity(bp, l.Value+r.Value, l.Dim)
+}
+
+func (bp *BinaryMinus) EvalQuantity(env Env) (Quantity, error) {
+	l, r, err := quantityOperands(bp.Left, bp.Right, env)
+	if err != nil {
+		return Quantity{}, err
+	}
+	if err := sameDimension(bp, l, r); err != nil {
+		return Quantity{}, err
+	}
+	return finiteQuantity(bp, l.Value-r.Value, l.Dim)
+}
+
+func (bp *BinaryMultiply) EvalQuantity(env Env) (Quantity, error) {
+	l, r, err := quantityOperands(bp.Left, bp.Right, env)
+	if err != nil {
+		return Quantity{}, err
+	}
+	return finiteQuantity(bp, l.Value*r.Value, l.Dim.add(r.Dim, 1))
+}
+
+func (bp *BinaryDivide) EvalQuantity(env Env) (Quantity, error) {
+	l, r, err := quantityOperands(bp.Left, bp.Right, env)
+	if err != nil {
+		return Quantity{}, err
+	}
+	if r.Value == 0 {
+		return Quantity{}, &DivisionByZeroError{Expr: String(bp)}
+	}
+	return finiteQuantity(bp, l.Value/r.Value, l.Dim.add(r.Dim, -1))
+}
+
+func (bp *BinaryModulo) EvalQuantity(env Env) (Quantity, error) {
+	l, r, err := quantityOperands(bp.Left, bp.Right, env)
+	if err != nil {
+		return Quantity{}, err
+	}
+	if err := sameDimension(bp, l, r); err != nil {
+		return Quantity{}, err
+	}
+	if r.Value == 0 {
+		return Quantity{}, &DivisionByZeroError{Expr: String(bp)}
+	}
+	return finiteQuantity(bp, math.Mod(l.Value, r.Value), l.Dim)
+}
+
+// EvalQuantity requires a dimensionless exponent that raises every base
+// dimension to a whole power, so m^2 is fine but m^0.5 isn't
+func (bp *BinaryPower) EvalQuantity(env Env) (Quantity, error) {
+	l, r, err := quantityOperands(bp.Left, bp.Right, env)
+	if err != nil {
+		return Quantity{}, err
+	}
+	if !r.Dim.dimensionless() {
+		return Quantity{}, &DimensionError{Expr: String(bp), Msg: fmt.Sprintf("exponent has dimension %s", r.Dim)}
+	}
+	dim, err := checkPower(bp, l.Dim, r.Value)
+	if err != nil {
+		return Quantity{}, err
+	}
+	if l.Value == 0 && r.Value < 0 {
+		return Quantity{}, &DivisionByZeroError{Expr: String(bp)}
+	}
+	return finiteQuantity(bp, math.Pow(l.Value, r.Value), dim)
+}
+
+func (um *UnaryMinus) EvalQuantity(env Env) (Quantity, error) {
+	q, err := evaluateQuantity(um.Operand, env)
+	if err != nil {
+		return Quantity{}, err
+	}
+	return Quantity{Value: -q.Value, Dim: q.Dim}, nil
+}
+
+// dimensionPreserving names the functions whose arguments may have any
+// dimension, as long as they share it, and whose result has it as well
+var dimensionPreserving = map[string]bool{
+	"abs":   true,
+	"min":   true,
+	"max":   true,
+	"hypot": true,
+}
+
+// EvalQuantity requires dimensionless arguments, except for the functions
+// in dimensionPreserving, atan2 of a ratio of like quantities, and sqrt and
+// cbrt, which take the root of the dimension too
+func (c *Call) EvalQuantity(env Env) (Quantity, error) {
+	args := make([]float64, len(c.Args))
+	var dim Dimension
+	for i, arg := range c.Args {
+		q, err := evaluateQuantity(arg, env)
+		if err != nil {
+			return Quantity{}, err
+		}
+		if i > 0 && q.Dim != dim {
+			return Quantity{}, &DimensionError{Expr: String(c), Msg: fmt.Sprintf("arguments in %s and %s", dim, q.Dim)}
+		}
+		dim, args[i] = q.Dim, q.Value
+	}
+
+	var err error
+	switch name := c.Fn.Name; {
+	case dimensionPreserving[name]:
+	case name == "atan2":
+		dim = Dimension{}
+	case name == "sqrt":
+		dim, err = checkRoot(c, dim, 2)
+	case name == "cbrt":
+		dim, err = checkRoot(c, dim, 3)
+	case !dim.dimensionless():
+		err = &DimensionError{Expr: String(c), Msg: fmt.Sprintf("%s needs a dimensionless argument, got %s", name, dim)}
+	}
+	if err != nil {
+		return Quantity{}, err
+	}
+	return finiteQuantity(c, c.Fn.Fn(args), dim)
+}
+
+func (c *Conditional) EvalQuantity(env Env) (Quantity, error) {
+	b, err := c.branch(env, quantities)
+	if err != nil {
+		return Quantity{}, err
+	}
+	return evaluateQuantity(b, env)
+}
+
+// EvalQuantity keeps the value in SI base units and records the target
+// unit for display
+func (c *Conversion) EvalQuantity(env Env) (Quantity, error) {
+	q, t, err := quantityOperands(c.Operand, c.Target, env)
+	if err != nil {
+		return Quantity{}, err
+	}
+	if err := sameDimension(c, q, t); err != nil {
+		return Quantity{}, err
+	}
+	q.Display = &Unit{Symbol: unitName(c.Target), Scale: t.Value, Dim: t.Dim}
+	return q, nil
+}
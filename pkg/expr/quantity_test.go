@@ -0,0 +1,84 @@
+package expr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuantities(t *testing.T) {
+	tests := map[string]struct {
+		src  string
+		want string // formatted with Format
+		err  error
+	}{
+		"conversion":           {src: "3 ft to m", want: "0.9144 m"},
+		"compound conversion":  {src: "5 km / 2 h to km/h", want: "2.5 km/h"},
+		"si result":            {src: "5 km / 2 h", want: "0.694444444444444 m/s"},
+		"power of a unit":      {src: "2 m * 3 m to m^2", want: "6 m^2"},
+		"scalar result":        {src: "1 km / 1 m", want: "1000"},
+		"unit comparison":      {src: "1 km < 1 mi", want: "true"},
+		"minutes":              {src: "5 min to s", want: "300 s"},
+		"min function":         {src: "min(5, 3)", want: "3"},
+		"minutes in min":       {src: "min(5 min, 200 s) to s", want: "200 s"},
+		"sum mismatch":         {src: "1 m + 1 s", err: &DimensionError{Expr: "((1 * m) + (1 * s))", Msg: "m vs s"}},
+		"comparison mismatch":  {src: "2 m < 3 s", err: &DimensionError{Expr: "((2 * m) < (3 * s))", Msg: "m vs s"}},
+		"conversion mismatch":  {src: "1 m to s", err: &DimensionError{Expr: "((1 * m) to s)", Msg: "m vs s"}},
+		"function of a length": {src: "log(1 m)", err: &DimensionError{Expr: "log((1 * m))", Msg: "log needs a dimensionless argument, got m"}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := Eval(e, nil)
+			if !reflect.DeepEqual(tc.err, err) {
+				t.Fatalf("expected: %v, got: %v", tc.err, err)
+			}
+			if err == nil && Format(v) != tc.want {
+				t.Fatalf("expected: %s, got: %s", tc.want, Format(v))
+			}
+		})
+	}
+}
+
+// TestUnitShadowing checks that a bound variable hides the unit of the same
+// name everywhere but in the target of a conversion
+func TestUnitShadowing(t *testing.T) {
+	env := Env{"m": 2.0, "km": 3.0}
+
+	tests := map[string]struct {
+		src  string
+		want string // formatted with Format
+		err  error
+	}{
+		"variable":            {src: "5 * m", want: "10"},
+		"other units":         {src: "1 ft + 1 in to cm", want: "33.02 cm"},
+		"conversion target":   {src: "3 ft to m", want: "0.9144 m"},
+		"after a number":      {src: "5 m", err: &SyntaxError{Pos: 3, Msg: `variable "m" shadows the unit of the same name`}},
+		"inside parentheses":  {src: "(5 km)", err: &SyntaxError{Pos: 4, Msg: `variable "km" shadows the unit of the same name`}},
+		"called function":     {src: "5 min(1, 2)", err: &SyntaxError{Pos: 3, Msg: `unexpected "min"`}},
+		"unknown identifier":  {src: "5 * mm + x", err: &SyntaxError{Pos: 10, Msg: `undefined variable "x"`}},
+		"unbound unit symbol": {src: "5 * mm to m", want: "0.005 m"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := ParseEnv(tc.src, env)
+			if !reflect.DeepEqual(tc.err, err) {
+				t.Fatalf("expected: %v, got: %v", tc.err, err)
+			}
+			if err != nil {
+				return
+			}
+			v, err := Eval(e, env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if Format(v) != tc.want {
+				t.Fatalf("expected: %s, got: %s", tc.want, Format(v))
+			}
+		})
+	}
+}
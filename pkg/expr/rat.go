@@ -0,0 +1,267 @@
+package expr
+
+import (
+	"math/big"
+)
+
+// maxExactExponent bounds integer exponents evaluated exactly, since the
+// size of the result grows linearly with the exponent
+const maxExactExponent = 1 << 16
+
+// maxExactBits bounds the size in bits of the numerator and denominator of
+// an exact power, which also grows with the size of the base
+const maxExactBits = 1 << 17
+
+// ratEval evaluates a node exactly as a rational number
+type ratEval interface {
+	EvalRat(env Env) (*big.Rat, error)
+}
+
+// evaluateRat evaluates e through ratEval
+func evaluateRat(e Node, env Env) (*big.Rat, error) {
+	re, ok := e.(ratEval)
+	if !ok {
+		return nil, numberExpected("rat", e)
+	}
+	return re.EvalRat(env)
+}
+
+// ratOperands evaluates both sides of a binary node exactly
+func ratOperands(left, right Node, env Env) (*big.Rat, *big.Rat, error) {
+	l, err := evaluateRat(left, env)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := evaluateRat(right, env)
+	if err != nil {
+		return nil, nil, err
+	}
+	return l, r, nil
+}
+
+// ratFromFloat converts v exactly; the float64 value is what the node
+// holds, so 0.1 written in a tree built from Go is not 1/10
+func ratFromFloat(e Node, v float64) (*big.Rat, error) {
+	r := new(big.Rat)
+	if r.SetFloat64(v) == nil {
+		return nil, &OverflowError{Expr: String(e)}
+	}
+	return r, nil
+}
+
+// ratFloor returns the largest integer not greater than r
+func ratFloor(r *big.Rat) *big.Int {
+	// Denominators are always positive, so Euclidean division floors
+	return new(big.Int).Div(r.Num(), r.Denom())
+}
+
+// ratTrunc returns the integer part of r
+func ratTrunc(r *big.Rat) *big.Int {
+	return new(big.Int).Quo(r.Num(), r.Denom())
+}
+
+func (c *Constant) EvalRat(env Env) (*big.Rat, error) {
+	if c.text != "" {
+		if r, ok := new(big.Rat).SetString(c.text); ok {
+			return r, nil
+		}
+	}
+	return ratFromFloat(c, c.Value)
+}
+
+func (v *Variable) EvalRat(env Env) (*big.Rat, error) {
+	switch value := env[v.Name].(type) {
+	case *big.Rat:
+		return value, nil
+	case *big.Float:
+		r, _ := value.Rat(nil)
+		return r, nil
+	case float64:
+		return ratFromFloat(v, value)
+	}
+	return nil, v.notNumber(env)
+}
+
+func (bp *BinaryPlus) EvalRat(env Env) (*big.Rat, error) {
+	l, r, err := ratOperands(bp.Left, bp.Right, env)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Rat).Add(l, r), nil
+}
+
+func (bp *BinaryMinus) EvalRat(env Env) (*big.Rat, error) {
+	l, r, err := ratOperands(bp.Left, bp.Right, env)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Rat).Sub(l, r), nil
+}
+
+func (bp *BinaryMultiply) EvalRat(env Env) (*big.Rat, error) {
+	l, r, err := ratOperands(bp.Left, bp.Right, env)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Rat).Mul(l, r), nil
+}
+
+func (bp *BinaryDivide) EvalRat(env Env) (*big.Rat, error) {
+	l, r, err := ratOperands(bp.Left, bp.Right, env)
+	if err != nil {
+		return nil, err
+	}
+	if r.Sign() == 0 {
+		return nil, &DivisionByZeroError{Expr: String(bp)}
+	}
+	return new(big.Rat).Quo(l, r), nil
+}
+
+// EvalRat truncates the quotient like math.Mod, so the result has the sign
+// of the dividend
+func (bp *BinaryModulo) EvalRat(env Env) (*big.Rat, error) {
+	l, r, err := ratOperands(bp.Left, bp.Right, env)
+	if err != nil {
+		return nil, err
+	}
+	if r.Sign() == 0 {
+		return nil, &DivisionByZeroError{Expr: String(bp)}
+	}
+	q := new(big.Rat).SetInt(ratTrunc(new(big.Rat).Quo(l, r)))
+	return new(big.Rat).Sub(l, q.Mul(q, r)), nil
+}
+
+// EvalRat only handles integer exponents; anything else is irrational in
+// general and reported as an InexactError
+func (bp *BinaryPower) EvalRat(env Env) (*big.Rat, error) {
+	l, r, err := ratOperands(bp.Left, bp.Right, env)
+	if err != nil {
+		return nil, err
+	}
+	if !r.IsInt() {
+		return nil, &InexactError{Expr: String(bp)}
+	}
+	n := r.Num()
+	if n.CmpAbs(big.NewInt(maxExactExponent)) > 0 {
+		return nil, &OverflowError{Expr: String(bp)}
+	}
+	if l.Sign() == 0 && n.Sign() < 0 {
+		return nil, &DivisionByZeroError{Expr: String(bp)}
+	}
+	exp := new(big.Int).Abs(n)
+	if bits := max(l.Num().BitLen(), l.Denom().BitLen()) - 1; int64(bits)*exp.Int64() > maxExactBits {
+		return nil, &OverflowError{Expr: String(bp)}
+	}
+	num := new(big.Int).Exp(l.Num(), exp, nil)
+	den := new(big.Int).Exp(l.Denom(), exp, nil)
+	if n.Sign() < 0 {
+		num, den = den, num
+	}
+	return new(big.Rat).SetFrac(num, den), nil
+}
+
+func (um *UnaryMinus) EvalRat(env Env) (*big.Rat, error) {
+	v, err := evaluateRat(um.Operand, env)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Rat).Neg(v), nil
+}
+
+// exactFunctions implements the functions whose result is rational for
+// rational arguments
+var exactFunctions = map[string]func(args []*big.Rat) *big.Rat{
+	"abs": func(args []*big.Rat) *big.Rat {
+		return new(big.Rat).Abs(args[0])
+	},
+	"floor": func(args []*big.Rat) *big.Rat {
+		return new(big.Rat).SetInt(ratFloor(args[0]))
+	},
+	"ceil": func(args []*big.Rat) *big.Rat {
+		f := ratFloor(new(big.Rat).Neg(args[0]))
+		return new(big.Rat).SetInt(f.Neg(f))
+	},
+	"round": func(args []*big.Rat) *big.Rat {
+		// Half away from zero, like math.Round
+		half := new(big.Rat).Add(new(big.Rat).Abs(args[0]), big.NewRat(1, 2))
+		r := new(big.Rat).SetInt(ratFloor(half))
+		if args[0].Sign() < 0 {
+			r.Neg(r)
+		}
+		return r
+	},
+	"min": func(args []*big.Rat) *big.Rat {
+		m := args[0]
+		for _, v := range args[1:] {
+			if v.Cmp(m) < 0 {
+				m = v
+			}
+		}
+		return new(big.Rat).Set(m)
+	},
+	"max": func(args []*big.Rat) *big.Rat {
+		m := args[0]
+		for _, v := range args[1:] {
+			if v.Cmp(m) > 0 {
+				m = v
+			}
+		}
+		return new(big.Rat).Set(m)
+	},
+}
+
+func (c *Call) EvalRat(env Env) (*big.Rat, error) {
+	fn, ok := exactFunctions[c.Fn.Name]
+	if !ok {
+		return nil, &InexactError{Expr: String(c)}
+	}
+	args := make([]*big.Rat, len(c.Args))
+	for i, arg := range c.Args {
+		v, err := evaluateRat(arg, env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return fn(args), nil
+}
+
+// formatRat prints r as an integer or terminating decimal when it has one,
+// and as a fraction such as 1/3 otherwise
+func formatRat(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	// A fraction terminates iff its reduced denominator is 2^a * 5^b, in
+	// which case max(a, b) decimal places are exact
+	den := new(big.Int).Set(r.Denom())
+	places := 0
+	for _, p := range []int64{2, 5} {
+		n := 0
+		prime := big.NewInt(p)
+		m := new(big.Int)
+		for {
+			q, rem := new(big.Int).QuoRem(den, prime, m)
+			if rem.Sign() != 0 {
+				break
+			}
+			den = q
+			n++
+		}
+		if n > places {
+			places = n
+		}
+	}
+	if den.Cmp(big.NewInt(1)) != 0 {
+		return r.RatString()
+	}
+	return r.FloatString(places)
+}
+
+func (c *Conditional) EvalRat(env Env) (*big.Rat, error) {
+	b, err := c.branch(env, ratNumbers)
+	if err != nil {
+		return nil, err
+	}
+	return evaluateRat(b, env)
+}
@@ -0,0 +1,116 @@
+package expr
+
+import (
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := map[string]struct {
+		src   string
+		rpn   string
+		latex string
+	}{
+		"precedence":        {src: "1 + 2 * 3", rpn: "1 2 3 * +", latex: `1 + 2 \cdot 3`},
+		"grouped sum":       {src: "(1 + 2) * 3", rpn: "1 2 + 3 *", latex: `\left(1 + 2\right) \cdot 3`},
+		"right difference":  {src: "1 - (2 - 3)", rpn: "1 2 3 - -", latex: `1 - \left(2 - 3\right)`},
+		"fraction":          {src: "x / (y + 1)", rpn: "x y 1 + /", latex: `\frac{x}{y + 1}`},
+		"modulo":            {src: "x % 3", rpn: "x 3 %", latex: `x \bmod 3`},
+		"nested power":      {src: "2 ^ 3 ^ 2", rpn: "2 3 2 ^ ^", latex: `2^{3^{2}}`},
+		"power of power":    {src: "(2 ^ 3) ^ 2", rpn: "2 3 ^ 2 ^", latex: `\left(2^{3}\right)^{2}`},
+		"negated power":     {src: "-x ^ 2", rpn: "x 2 ^ neg", latex: `-x^{2}`},
+		"power of negation": {src: "(-2) ^ 2", rpn: "2 neg 2 ^", latex: `\left(-2\right)^{2}`},
+		"negative exponent": {src: "2 ^ -1", rpn: "2 1 neg ^", latex: `2^{-1}`},
+		"double negation":   {src: "--x", rpn: "x neg neg", latex: `-\left(-x\right)`},
+		"negated sum":       {src: "-(x + 1)", rpn: "x 1 + neg", latex: `-\left(x + 1\right)`},
+		"calls":             {src: "sqrt(x) + max(1, 2)", rpn: "x sqrt 1 2 max/2 +", latex: `\sqrt{x} + \max\left(1, 2\right)`},
+		"logic":             {src: "!(x < 1) && y", rpn: "x 1 < ! y &&", latex: `\lnot \left(x < 1\right) \land y`},
+		"conditional": {
+			src:   "1 < x ? 1 : 0",
+			rpn:   "1 x < 1 0 ?:",
+			latex: `\begin{cases} 1 & \text{if } 1 < x \\ 0 & \text{otherwise} \end{cases}`,
+		},
+		"conversion": {src: "5 km to m", rpn: "5 km * m to", latex: `5 \cdot \mathrm{km} \to \mathrm{m}`},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rpn, err := RPN(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rpn != tc.rpn {
+				t.Fatalf("expected: %s, got: %s", tc.rpn, rpn)
+			}
+			latex, err := LaTeX(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if latex != tc.latex {
+				t.Fatalf("expected: %s, got: %s", tc.latex, latex)
+			}
+		})
+	}
+}
+
+func TestDOT(t *testing.T) {
+	tests := map[string]struct {
+		src  string
+		want string
+	}{
+		"leaf": {
+			src: "x",
+			want: `digraph expr {
+  node [shape=box];
+  n0 [label="x"];
+}`,
+		},
+		"negated power": {
+			src: "-(2 ^ x) + 1",
+			want: `digraph expr {
+  node [shape=box];
+  n0 [label="+"];
+  n1 [label="neg"];
+  n2 [label="^"];
+  n3 [label="2"];
+  n2 -> n3;
+  n4 [label="x"];
+  n2 -> n4;
+  n1 -> n2;
+  n0 -> n1;
+  n5 [label="1"];
+  n0 -> n5;
+}`,
+		},
+		"call": {
+			src: "max(x, 2)",
+			want: `digraph expr {
+  node [shape=box];
+  n0 [label="max()"];
+  n1 [label="x"];
+  n0 -> n1;
+  n2 [label="2"];
+  n0 -> n2;
+}`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := DOT(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
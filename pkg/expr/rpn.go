@@ -0,0 +1,116 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// toRPN renders a node in reverse Polish notation, e.g. "1 2 3 * +"
+type toRPN interface {
+	ToRPN() string
+}
+
+// RPN renders e with ToRPN, failing if any of its nodes doesn't implement it
+func RPN(e Node) (string, error) {
+	if err := supports("rpn", e, func(n Node) bool { _, ok := n.(toRPN); return ok }); err != nil {
+		return "", err
+	}
+	return rpn(e), nil
+}
+
+// rpn renders a node that RPN has checked
+func rpn(e Node) string {
+	return e.(toRPN).ToRPN()
+}
+
+func rpnBinary(left, right Node, op string) string {
+	return fmt.Sprintf("%s %s %s", rpn(left), rpn(right), op)
+}
+
+func (c *Constant) ToRPN() string {
+	return c.ToString()
+}
+
+func (v *Variable) ToRPN() string {
+	return v.Name
+}
+
+func (bp *BinaryPlus) ToRPN() string {
+	return rpnBinary(bp.Left, bp.Right, "+")
+}
+
+func (bp *BinaryMinus) ToRPN() string {
+	return rpnBinary(bp.Left, bp.Right, "-")
+}
+
+func (bp *BinaryMultiply) ToRPN() string {
+	return rpnBinary(bp.Left, bp.Right, "*")
+}
+
+func (bp *BinaryDivide) ToRPN() string {
+	return rpnBinary(bp.Left, bp.Right, "/")
+}
+
+func (bp *BinaryModulo) ToRPN() string {
+	return rpnBinary(bp.Left, bp.Right, "%")
+}
+
+func (bp *BinaryPower) ToRPN() string {
+	return rpnBinary(bp.Left, bp.Right, "^")
+}
+
+// ToRPN uses "neg" so that negation isn't confused with subtraction
+func (um *UnaryMinus) ToRPN() string {
+	return rpn(um.Operand) + " neg"
+}
+
+// ToRPN suffixes variadic functions with their argument count, e.g.
+// "1 2 3 max/3", since it can't be inferred from the function alone
+func (c *Call) ToRPN() string {
+	parts := make([]string, 0, len(c.Args)+1)
+	for _, arg := range c.Args {
+		parts = append(parts, rpn(arg))
+	}
+	op := c.Fn.Name
+	if c.Fn.MinArgs != c.Fn.MaxArgs {
+		op = fmt.Sprintf("%s/%d", op, len(c.Args))
+	}
+	return strings.Join(append(parts, op), " ")
+}
+
+func (bp *BinaryLess) ToRPN() string {
+	return rpnBinary(bp.Left, bp.Right, "<")
+}
+
+func (bp *BinaryLessEqual) ToRPN() string {
+	return rpnBinary(bp.Left, bp.Right, "<=")
+}
+
+func (bp *BinaryEqual) ToRPN() string {
+	return rpnBinary(bp.Left, bp.Right, "==")
+}
+
+func (bp *BinaryAnd) ToRPN() string {
+	return rpnBinary(bp.Left, bp.Right, "&&")
+}
+
+func (bp *BinaryOr) ToRPN() string {
+	return rpnBinary(bp.Left, bp.Right, "||")
+}
+
+func (un *UnaryNot) ToRPN() string {
+	return rpn(un.Operand) + " !"
+}
+
+// ToRPN writes the condition first, e.g. "x 0 < x neg x ?:"
+func (c *Conditional) ToRPN() string {
+	return fmt.Sprintf("%s %s %s ?:", rpn(c.Cond), rpn(c.Then), rpn(c.Otherwise))
+}
+
+func (u *UnitLiteral) ToRPN() string {
+	return u.Unit.Symbol
+}
+
+func (c *Conversion) ToRPN() string {
+	return rpnBinary(c.Operand, c.Target, "to")
+}
@@ -0,0 +1,269 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// toSExpr renders a node as an S-expression, e.g. "(+ 1 (* 2 x))"
+type toSExpr interface {
+	ToSExpr() string
+}
+
+// SExpr renders e with ToSExpr, failing if any of its nodes doesn't
+// implement it
+func SExpr(e Node) (string, error) {
+	if err := supports("sexpr", e, func(n Node) bool { _, ok := n.(toSExpr); return ok }); err != nil {
+		return "", err
+	}
+	return sexpr(e), nil
+}
+
+// sexpr renders a node that SExpr has checked
+func sexpr(e Node) string {
+	return e.(toSExpr).ToSExpr()
+}
+
+func sexprList(head string, operands ...Node) string {
+	parts := make([]string, 0, len(operands)+1)
+	parts = append(parts, head)
+	for _, operand := range operands {
+		parts = append(parts, sexpr(operand))
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+func (c *Constant) ToSExpr() string {
+	return c.ToString()
+}
+
+func (v *Variable) ToSExpr() string {
+	return v.Name
+}
+
+func (bp *BinaryPlus) ToSExpr() string {
+	return sexprList("+", bp.Left, bp.Right)
+}
+
+func (bp *BinaryMinus) ToSExpr() string {
+	return sexprList("-", bp.Left, bp.Right)
+}
+
+func (bp *BinaryMultiply) ToSExpr() string {
+	return sexprList("*", bp.Left, bp.Right)
+}
+
+func (bp *BinaryDivide) ToSExpr() string {
+	return sexprList("/", bp.Left, bp.Right)
+}
+
+func (bp *BinaryModulo) ToSExpr() string {
+	return sexprList("%", bp.Left, bp.Right)
+}
+
+func (bp *BinaryPower) ToSExpr() string {
+	return sexprList("^", bp.Left, bp.Right)
+}
+
+func (um *UnaryMinus) ToSExpr() string {
+	return sexprList("neg", um.Operand)
+}
+
+func (c *Call) ToSExpr() string {
+	return sexprList(c.Fn.Name, c.Args...)
+}
+
+func (bp *BinaryLess) ToSExpr() string {
+	return sexprList("<", bp.Left, bp.Right)
+}
+
+func (bp *BinaryLessEqual) ToSExpr() string {
+	return sexprList("<=", bp.Left, bp.Right)
+}
+
+func (bp *BinaryEqual) ToSExpr() string {
+	return sexprList("==", bp.Left, bp.Right)
+}
+
+func (bp *BinaryAnd) ToSExpr() string {
+	return sexprList("&&", bp.Left, bp.Right)
+}
+
+func (bp *BinaryOr) ToSExpr() string {
+	return sexprList("||", bp.Left, bp.Right)
+}
+
+func (un *UnaryNot) ToSExpr() string {
+	return sexprList("!", un.Operand)
+}
+
+func (c *Conditional) ToSExpr() string {
+	return sexprList("if", c.Cond, c.Then, c.Otherwise)
+}
+
+// ToSExpr marks the symbol as a unit so that it isn't read back as a
+// variable: "(unit km)"
+func (u *UnitLiteral) ToSExpr() string {
+	return "(unit " + u.Unit.Symbol + ")"
+}
+
+func (c *Conversion) ToSExpr() string {
+	return sexprList("to", c.Operand, c.Target)
+}
+
+// maxSExprDepth bounds how deeply lists may nest, so that hostile input
+// can't exhaust the stack; encoding/json applies the same limit to DecodeJSON
+const maxSExprDepth = 10000
+
+// sexprParser reads the S-expressions written by ToSExpr. Lists start with
+// an operator, "neg", "if", "unit", "to" or a function name; "-" with a
+// single operand is accepted as negation too.
+type sexprParser struct {
+	runes []rune
+	pos   int
+	depth int // lists currently open
+}
+
+// ParseSExpr builds a tree from an S-expression such as
+// "(+ 1 (- 2 (* 3 (/ 4 1))))". Lists may nest at most 10000 deep.
+func ParseSExpr(src string) (Node, error) {
+	p := &sexprParser{runes: []rune(src)}
+	e, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.runes) {
+		return nil, p.errorf(p.pos, "unexpected %q after the expression", p.runes[p.pos])
+	}
+	return e, nil
+}
+
+func (p *sexprParser) errorf(pos int, format string, args ...interface{}) error {
+	return &SyntaxError{Pos: pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *sexprParser) skipSpace() {
+	for p.pos < len(p.runes) && unicode.IsSpace(p.runes[p.pos]) {
+		p.pos++
+	}
+}
+
+// atom reads the run of characters up to the next space or parenthesis
+func (p *sexprParser) atom() (string, int) {
+	start := p.pos
+	for p.pos < len(p.runes) {
+		r := p.runes[p.pos]
+		if unicode.IsSpace(r) || r == '(' || r == ')' {
+			break
+		}
+		p.pos++
+	}
+	return string(p.runes[start:p.pos]), start
+}
+
+func (p *sexprParser) parse() (Node, error) {
+	p.skipSpace()
+	if p.pos >= len(p.runes) {
+		return nil, p.errorf(p.pos, "unexpected end of input")
+	}
+	switch p.runes[p.pos] {
+	case '(':
+		return p.list()
+	case ')':
+		return nil, p.errorf(p.pos, "unexpected ')'")
+	}
+
+	text, pos := p.atom()
+	if v, err := strconv.ParseFloat(text, 64); err == nil && !strings.ContainsAny(text, "nNiI") {
+		return &Constant{Value: v, text: text}, nil
+	}
+	if isIdentifier(text) {
+		return &Variable{Name: text}, nil
+	}
+	return nil, p.errorf(pos, "%q is not a number or variable", text)
+}
+
+// list parses "(head operands...)" and builds the node named by head
+func (p *sexprParser) list() (Node, error) {
+	open := p.pos
+	if p.depth++; p.depth > maxSExprDepth {
+		return nil, p.errorf(open, "lists nested deeper than %d", maxSExprDepth)
+	}
+	defer func() { p.depth-- }()
+	p.pos++
+	p.skipSpace()
+	head, pos := p.atom()
+	if head == "" {
+		return nil, p.errorf(p.pos, "expected an operator or function name after '('")
+	}
+
+	var args []Node
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.runes) {
+			return nil, p.errorf(open, "unclosed '('")
+		}
+		if p.runes[p.pos] == ')' {
+			p.pos++
+			break
+		}
+		arg, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+
+	if head == "neg" || head == "-" && len(args) == 1 {
+		if len(args) != 1 {
+			return nil, p.errorf(pos, "neg expects 1 operand, got %d", len(args))
+		}
+		return &UnaryMinus{args[0]}, nil
+	}
+	switch head {
+	case "!":
+		if len(args) != 1 {
+			return nil, p.errorf(pos, "! expects 1 operand, got %d", len(args))
+		}
+		return &UnaryNot{args[0]}, nil
+	case "if":
+		if len(args) != 3 {
+			return nil, p.errorf(pos, "if expects 3 operands, got %d", len(args))
+		}
+		return &Conditional{args[0], args[1], args[2]}, nil
+	case "unit":
+		var v *Variable
+		if len(args) == 1 {
+			v, _ = args[0].(*Variable)
+		}
+		if v == nil {
+			return nil, p.errorf(pos, "unit expects 1 unit symbol")
+		}
+		u, ok := LookupUnit(v.Name)
+		if !ok {
+			return nil, p.errorf(pos, "unknown unit %q", v.Name)
+		}
+		return &UnitLiteral{u}, nil
+	case "to":
+		if len(args) != 2 {
+			return nil, p.errorf(pos, "to expects 2 operands, got %d", len(args))
+		}
+		return &Conversion{args[0], args[1]}, nil
+	}
+	if build, ok := binaryNodes[head]; ok {
+		if len(args) != 2 {
+			return nil, p.errorf(pos, "%s expects 2 operands, got %d", head, len(args))
+		}
+		return build(args[0], args[1]), nil
+	}
+	fn, ok := LookupFunction(head)
+	if !ok {
+		return nil, p.errorf(pos, "unknown operator or function %q", head)
+	}
+	if !fn.Accepts(len(args)) {
+		return nil, p.errorf(pos, "%s expects %s argument(s), got %d", fn.Name, fn.Arity(), len(args))
+	}
+	return &Call{Fn: fn, Args: args}, nil
+}
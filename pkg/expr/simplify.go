@@ -0,0 +1,313 @@
+package expr
+
+import (
+	"math"
+	"sort"
+)
+
+// simplifier rewrites a node into an equivalent, simpler tree. The operands
+// of a simplified tree are shared with the original, never modified.
+type simplifier interface {
+	Simplify() Node
+}
+
+// Simplify folds constant subtrees and removes identities from e; nodes
+// that don't implement simplifier are kept as they are
+func Simplify(e Node) Node {
+	if s, ok := e.(simplifier); ok {
+		return s.Simplify()
+	}
+	return e
+}
+
+// constantValue reports whether e is a constant and its value
+func constantValue(e Node) (float64, bool) {
+	c, ok := e.(*Constant)
+	if !ok {
+		return 0, false
+	}
+	return c.Value, true
+}
+
+func isConstant(e Node, v float64) bool {
+	c, ok := constantValue(e)
+	return ok && c == v
+}
+
+// mayCarryUnits reports whether any of es may evaluate to a quantity with
+// a dimension: variables may be bound to quantities, so only trees without
+// variables and units are known to be dimensionless
+func mayCarryUnits(es ...Node) bool {
+	found := false
+	for _, e := range es {
+		Inspect(e, func(n Node) bool {
+			switch n.(type) {
+			case *Variable, *UnitLiteral, *Conversion:
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// fold evaluates e if all its operands are constants, keeping e when
+// evaluation fails so that errors still surface when the tree is evaluated
+func fold(e Node, operands ...Node) Node {
+	for _, op := range operands {
+		if _, ok := constantValue(op); !ok {
+			return e
+		}
+	}
+	v, err := evaluate(e, nil)
+	if err != nil {
+		return e
+	}
+	return &Constant{Value: v}
+}
+
+// rank orders node kinds for the canonical operand order of commutative
+// operators: constants first, then variables, calls and everything else
+func rank(e Node) int {
+	switch e.(type) {
+	case *Constant:
+		return 0
+	case *Variable:
+		return 1
+	case *Call:
+		return 2
+	}
+	return 3
+}
+
+// canonical sorts operands by rank and then by their string form
+func canonical(operands []Node) {
+	sort.SliceStable(operands, func(i, j int) bool {
+		ri, rj := rank(operands[i]), rank(operands[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return String(operands[i]) < String(operands[j])
+	})
+}
+
+// chain describes an associative and commutative binary operator so that
+// nested uses of it can be flattened, folded and reordered together
+type chain struct {
+	identity float64
+	// dimensioned is whether the operands must share a dimension, so that
+	// the dimensionless identity can't be dropped next to operands that
+	// may carry units without hiding the mismatch
+	dimensioned bool
+	combine     func(a, b float64) float64
+	split       func(e Node) (left, right Node, ok bool)
+	build       func(left, right Node) Node
+}
+
+var plusChain = chain{
+	identity:    0,
+	dimensioned: true,
+	combine:     func(a, b float64) float64 { return a + b },
+	split: func(e Node) (Node, Node, bool) {
+		if bp, ok := e.(*BinaryPlus); ok {
+			return bp.Left, bp.Right, true
+		}
+		return nil, nil, false
+	},
+	build: func(l, r Node) Node { return &BinaryPlus{l, r} },
+}
+
+var multiplyChain = chain{
+	identity: 1,
+	combine:  func(a, b float64) float64 { return a * b },
+	split: func(e Node) (Node, Node, bool) {
+		if bp, ok := e.(*BinaryMultiply); ok {
+			return bp.Left, bp.Right, true
+		}
+		return nil, nil, false
+	},
+	build: func(l, r Node) Node { return &BinaryMultiply{l, r} },
+}
+
+// collect appends the simplified operands of the chain rooted at e
+func (c chain) collect(e Node, operands []Node) []Node {
+	left, right, ok := c.split(e)
+	if !ok {
+		s := Simplify(e)
+		if _, _, ok := c.split(s); !ok {
+			return append(operands, s)
+		}
+		left, right, _ = c.split(s)
+	}
+	operands = c.collect(left, operands)
+	return c.collect(right, operands)
+}
+
+// Simplify flattens the chain rooted at e, folds its constants into one,
+// drops the identity where that can't hide an error and rebuilds it left-associated in canonical order
+func (c chain) simplify(e Node) Node {
+	var (
+		operands  []Node
+		folded    = c.identity
+		constants []Node
+	)
+	for _, op := range c.collect(e, nil) {
+		if v, ok := constantValue(op); ok {
+			folded = c.combine(folded, v)
+			constants = append(constants, op)
+			continue
+		}
+		operands = append(operands, op)
+	}
+
+	// A folded 0 doesn't absorb the other factors: evaluating them may fail
+	// or give a quantity, which 0 * x must keep
+	switch {
+	case math.IsInf(folded, 0) || math.IsNaN(folded):
+		// Keep the constants apart so evaluation reports the overflow
+		operands = append(operands, constants...)
+	case folded != c.identity || len(operands) == 0 || c.dimensioned && len(constants) > 0 && mayCarryUnits(operands...):
+		operands = append(operands, &Constant{Value: folded})
+	}
+	canonical(operands)
+
+	result := operands[0]
+	for _, op := range operands[1:] {
+		result = c.build(result, op)
+	}
+	return result
+}
+
+func (c *Constant) Simplify() Node {
+	return c
+}
+
+func (v *Variable) Simplify() Node {
+	return v
+}
+
+func (bp *BinaryPlus) Simplify() Node {
+	return plusChain.simplify(bp)
+}
+
+func (bp *BinaryMultiply) Simplify() Node {
+	e := multiplyChain.simplify(bp)
+	// -1 sorts first among the operands, so (-1 * x) becomes -x
+	if m, ok := e.(*BinaryMultiply); ok && isConstant(m.Left, -1) {
+		return &UnaryMinus{m.Right}
+	}
+	return e
+}
+
+func (bp *BinaryMinus) Simplify() Node {
+	l, r := Simplify(bp.Left), Simplify(bp.Right)
+	// Like x + 0, x - 0 and 0 - x report a mismatch when x carries units
+	switch {
+	case isConstant(r, 0) && !mayCarryUnits(l):
+		return l
+	case isConstant(l, 0) && !mayCarryUnits(r):
+		return Simplify(&UnaryMinus{r})
+	}
+	if neg, ok := r.(*UnaryMinus); ok {
+		return Simplify(&BinaryPlus{l, neg.Operand})
+	}
+	return fold(&BinaryMinus{l, r}, l, r)
+}
+
+func (bp *BinaryDivide) Simplify() Node {
+	l, r := Simplify(bp.Left), Simplify(bp.Right)
+	if isConstant(r, 1) {
+		return l
+	}
+	return fold(&BinaryDivide{l, r}, l, r)
+}
+
+func (bp *BinaryModulo) Simplify() Node {
+	l, r := Simplify(bp.Left), Simplify(bp.Right)
+	return fold(&BinaryModulo{l, r}, l, r)
+}
+
+func (bp *BinaryPower) Simplify() Node {
+	l, r := Simplify(bp.Left), Simplify(bp.Right)
+	if isConstant(r, 1) {
+		return l
+	}
+	// x^0 and 1^x aren't rewritten to 1: evaluating x may fail, and folding
+	// already covers a constant x
+	return fold(&BinaryPower{l, r}, l, r)
+}
+
+func (um *UnaryMinus) Simplify() Node {
+	operand := Simplify(um.Operand)
+	if inner, ok := operand.(*UnaryMinus); ok {
+		return inner.Operand
+	}
+	if v, ok := constantValue(operand); ok {
+		return &Constant{Value: -v}
+	}
+	return &UnaryMinus{operand}
+}
+
+func (c *Call) Simplify() Node {
+	args := make([]Node, len(c.Args))
+	for i, arg := range c.Args {
+		args[i] = Simplify(arg)
+	}
+	return fold(&Call{Fn: c.Fn, Args: args}, args...)
+}
+
+func (bp *BinaryLess) Simplify() Node {
+	return &BinaryLess{Simplify(bp.Left), Simplify(bp.Right)}
+}
+
+func (bp *BinaryLessEqual) Simplify() Node {
+	return &BinaryLessEqual{Simplify(bp.Left), Simplify(bp.Right)}
+}
+
+func (bp *BinaryEqual) Simplify() Node {
+	return &BinaryEqual{Simplify(bp.Left), Simplify(bp.Right)}
+}
+
+func (bp *BinaryAnd) Simplify() Node {
+	return &BinaryAnd{Simplify(bp.Left), Simplify(bp.Right)}
+}
+
+func (bp *BinaryOr) Simplify() Node {
+	return &BinaryOr{Simplify(bp.Left), Simplify(bp.Right)}
+}
+
+// Simplify removes a double negation only around operators that always
+// give a bool, since !!x rejects a number x
+func (un *UnaryNot) Simplify() Node {
+	operand := Simplify(un.Operand)
+	if inner, ok := operand.(*UnaryNot); ok {
+		switch inner.Operand.(type) {
+		case *BinaryLess, *BinaryLessEqual, *BinaryEqual, *BinaryAnd, *BinaryOr, *UnaryNot:
+			return inner.Operand
+		}
+	}
+	return &UnaryNot{operand}
+}
+
+// Simplify drops the condition when both branches are the same, provided
+// it evaluates without variables, units or errors that dropping it would
+// hide
+func (c *Conditional) Simplify() Node {
+	cond, then, otherwise := Simplify(c.Cond), Simplify(c.Then), Simplify(c.Otherwise)
+	if String(then) == String(otherwise) && !mayCarryUnits(cond) {
+		if _, err := evaluateBool(cond, nil, floatNumbers); err == nil {
+			return then
+		}
+	}
+	return &Conditional{cond, then, otherwise}
+}
+
+func (u *UnitLiteral) Simplify() Node {
+	return u
+}
+
+// Simplify keeps the target as written, since it names the unit the
+// result is displayed in
+func (c *Conversion) Simplify() Node {
+	return &Conversion{Simplify(c.Operand), c.Target}
+}
@@ -0,0 +1,53 @@
+package expr
+
+import (
+	"testing"
+)
+
+func TestSimplify(t *testing.T) {
+	tests := map[string]struct {
+		src  string
+		want string // ToString of the simplified tree
+	}{
+		"fold":              {src: "2 * (3 + 4) - 1", want: "13"},
+		"canonical order":   {src: "y + 2 + x + 1", want: "((3 + x) + y)"},
+		"times one":         {src: "1 * x * 1", want: "x"},
+		"plus zero":         {src: "0 + sin(1) + 0", want: "0.8414709848078965"},
+		"divide by one":     {src: "sqrt(2) / 1", want: "1.4142135623730951"},
+		"power of one":      {src: "x ^ 1", want: "x"},
+		"constant to zero":  {src: "5 ^ 0", want: "1"},
+		"one to constant":   {src: "1 ^ 5", want: "1"},
+		"minus one":         {src: "-1 * x", want: "-x"},
+		"double minus":      {src: "--x", want: "x"},
+		"minus negation":    {src: "x - -y", want: "(x + y)"},
+		"double not":        {src: "!!(x < 1)", want: "(x < 1)"},
+		"constant branches": {src: "1 < 2 ? x : x", want: "x"},
+
+		// Identities that would hide an error or a unit mismatch
+		"zero factor":             {src: "0 * x", want: "(0 * x)"},
+		"zero factor of error":    {src: "0 * (1/0)", want: "(0 * (1 / 0))"},
+		"error to zero":           {src: "(1/0) ^ 0", want: "((1 / 0) ^ 0)"},
+		"variable to zero":        {src: "x ^ 0", want: "(x ^ 0)"},
+		"one to error":            {src: "1 ^ (1/0)", want: "(1 ^ (1 / 0))"},
+		"one to variable":         {src: "1 ^ x", want: "(1 ^ x)"},
+		"zero plus variable":      {src: "x + 0", want: "(0 + x)"},
+		"minus zero":              {src: "x - 0", want: "(x - 0)"},
+		"zero minus":              {src: "0 - x", want: "(0 - x)"},
+		"double not of number":    {src: "!!x", want: "!!x"},
+		"branches after error":    {src: "(1/0) < 1 ? 2 : 2", want: "(((1 / 0) < 1) ? 2 : 2)"},
+		"branches after variable": {src: "x < 1 ? 2 : 2", want: "((x < 1) ? 2 : 2)"},
+		"overflow":                {src: "2 ^ 2000 * 0", want: "(0 * (2 ^ 2000))"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := String(Simplify(e)); got != tc.want {
+				t.Fatalf("expected: %s, got: %s", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Indices of the SI base dimensions in a dimension vector
+const (
+	dimLength = iota
+	dimMass
+	dimTime
+	dimCurrent
+	dimTemperature
+	dimAmount
+	dimLuminosity
+	numDimensions
+)
+
+// baseSymbols names the SI base unit of each dimension
+var baseSymbols = [numDimensions]string{"m", "kg", "s", "A", "K", "mol", "cd"}
+
+// Dimension holds the exponent of each SI base dimension, e.g. speed is
+// length^1 time^-1
+type Dimension [numDimensions]int
+
+func (d Dimension) dimensionless() bool {
+	return d == Dimension{}
+}
+
+func (d Dimension) add(o Dimension, sign int) Dimension {
+	for i := range d {
+		d[i] += sign * o[i]
+	}
+	return d
+}
+
+// String writes d in SI base units, e.g. "kg*m^2/s^2"; dimensionless
+// quantities print as "1"
+func (d Dimension) String() string {
+	var num, den []string
+	for i, exp := range d {
+		switch {
+		case exp > 0:
+			num = append(num, withExponent(baseSymbols[i], exp))
+		case exp < 0:
+			den = append(den, withExponent(baseSymbols[i], -exp))
+		}
+	}
+	s := strings.Join(num, "*")
+	if s == "" {
+		s = "1"
+	}
+	switch len(den) {
+	case 0:
+		return s
+	case 1:
+		return s + "/" + den[0]
+	}
+	return s + "/(" + strings.Join(den, "*") + ")"
+}
+
+func withExponent(symbol string, exp int) string {
+	if exp == 1 {
+		return symbol
+	}
+	return fmt.Sprintf("%s^%d", symbol, exp)
+}
+
+// Unit is a named multiple of a product of SI base units: one unit is scale
+// in the SI base units of its dimension
+type Unit struct {
+	Symbol string
+	Scale  float64
+	Dim    Dimension
+}
+
+// units holds every unit the parser can resolve. Registration may run
+// concurrently with parsing, so every access holds unitsMu.
+var (
+	unitsMu sync.RWMutex
+	units   = map[string]*Unit{}
+)
+
+// RegisterUnit makes symbol usable as a unit literal, replacing any unit
+// already registered with that symbol
+func RegisterUnit(symbol string, scale float64, dim Dimension) {
+	unitsMu.Lock()
+	defer unitsMu.Unlock()
+	units[symbol] = &Unit{Symbol: symbol, Scale: scale, Dim: dim}
+}
+
+// LookupUnit returns the unit registered under symbol
+func LookupUnit(symbol string) (*Unit, bool) {
+	unitsMu.RLock()
+	defer unitsMu.RUnlock()
+	u, ok := units[symbol]
+	return u, ok
+}
+
+// UnitSymbols returns the registered unit symbols in sorted order
+func UnitSymbols() []string {
+	unitsMu.RLock()
+	defer unitsMu.RUnlock()
+	symbols := make([]string, 0, len(units))
+	for symbol := range units {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+func init() {
+	var (
+		length   = Dimension{dimLength: 1}
+		mass     = Dimension{dimMass: 1}
+		time     = Dimension{dimTime: 1}
+		current  = Dimension{dimCurrent: 1}
+		volume   = Dimension{dimLength: 3}
+		force    = Dimension{dimMass: 1, dimLength: 1, dimTime: -2}
+		energy   = Dimension{dimMass: 1, dimLength: 2, dimTime: -2}
+		power    = Dimension{dimMass: 1, dimLength: 2, dimTime: -3}
+		pressure = Dimension{dimMass: 1, dimLength: -1, dimTime: -2}
+	)
+	for _, u := range []Unit{
+		{"m", 1, length}, {"km", 1e3, length}, {"cm", 1e-2, length}, {"mm", 1e-3, length},
+		{"um", 1e-6, length}, {"nm", 1e-9, length},
+		{"in", 0.0254, length}, {"ft", 0.3048, length}, {"yd", 0.9144, length}, {"mi", 1609.344, length},
+
+		{"kg", 1, mass}, {"g", 1e-3, mass}, {"mg", 1e-6, mass}, {"t", 1e3, mass},
+		{"lb", 0.45359237, mass}, {"oz", 0.028349523125, mass},
+
+		{"s", 1, time}, {"ms", 1e-3, time}, {"us", 1e-6, time}, {"ns", 1e-9, time},
+		{"min", 60, time}, {"h", 3600, time}, {"day", 86400, time},
+
+		{"A", 1, current}, {"mA", 1e-3, current},
+		{"K", 1, Dimension{dimTemperature: 1}},
+		{"mol", 1, Dimension{dimAmount: 1}},
+		{"cd", 1, Dimension{dimLuminosity: 1}},
+
+		{"L", 1e-3, volume}, {"mL", 1e-6, volume},
+		{"Hz", 1, Dimension{dimTime: -1}},
+		{"N", 1, force},
+		{"J", 1, energy}, {"kJ", 1e3, energy}, {"cal", 4.184, energy}, {"kcal", 4184, energy},
+		{"Wh", 3600, energy}, {"kWh", 3.6e6, energy},
+		{"W", 1, power}, {"kW", 1e3, power},
+		{"Pa", 1, pressure}, {"kPa", 1e3, pressure}, {"bar", 1e5, pressure},
+		{"C", 1, Dimension{dimCurrent: 1, dimTime: 1}},
+		{"V", 1, power.add(current, -1)},
+		{"ohm", 1, power.add(current, -2)},
+	} {
+		RegisterUnit(u.Symbol, u.Scale, u.Dim)
+	}
+}
+
+// UnitLiteral is a unit used as a value, such as the km in "5 km", which
+// parses as 5 * km
+type UnitLiteral struct {
+	Unit *Unit
+}
+
+// Conversion is "operand to target": the value of operand, displayed in the
+// unit that target, a product of powers of unit literals, evaluates to
+type Conversion struct {
+	Operand Node
+	Target  Node
+}
+
+// Eval returns the size of the unit in SI base units, so that unchecked
+// arithmetic on quantities works in those units
+func (u *UnitLiteral) Eval(env Env) float64 {
+	return u.Unit.Scale
+}
+
+func (u *UnitLiteral) ToString() string {
+	return u.Unit.Symbol
+}
+
+// Eval converts from SI base units into the target unit
+func (c *Conversion) Eval(env Env) float64 {
+	return c.Operand.(eval).Eval(env) / c.Target.(eval).Eval(env)
+}
+
+func (c *Conversion) ToString() string {
+	return fmt.Sprintf("(%s to %s)", String(c.Operand), String(c.Target))
+}
+
+// unitName writes a target unit compactly, e.g. "km/h" for the tree of
+// km / h
+func unitName(e Node) string {
+	switch n := e.(type) {
+	case *UnitLiteral:
+		return n.Unit.Symbol
+	case *BinaryMultiply:
+		return unitName(n.Left) + "*" + unitName(n.Right)
+	case *BinaryDivide:
+		right := unitName(n.Right)
+		if _, ok := n.Right.(*BinaryPower); !ok && strings.ContainsAny(right, "*/") {
+			right = "(" + right + ")"
+		}
+		return unitName(n.Left) + "/" + right
+	case *BinaryPower:
+		if exp, ok := constantValue(n.Right); ok {
+			return unitName(n.Left) + "^" + strconv.FormatFloat(exp, 'g', -1, 64)
+		}
+	}
+	return String(e)
+}
+
+// Quantity is a number with a dimension. Value is in SI base units;
+// Display, set by a conversion, is the unit to print the value in.
+type Quantity struct {
+	Value   float64
+	Dim     Dimension
+	Display *Unit
+}
+
+// String prints q in its display unit, or in SI base units without one.
+// Converting through SI base units rounds in the last place, so only 15
+// significant digits are printed: 3 ft to m is 0.9144 m.
+func (q Quantity) String() string {
+	if q.Display != nil {
+		return strconv.FormatFloat(q.Value/q.Display.Scale, 'g', 15, 64) + " " + q.Display.Symbol
+	}
+	s := strconv.FormatFloat(q.Value, 'g', 15, 64)
+	if q.Dim.dimensionless() {
+		return s
+	}
+	return s + " " + q.Dim.String()
+}
+
+// scalar reports whether q is a plain number
+func (q Quantity) scalar() bool {
+	return q.Dim.dimensionless() && q.Display == nil
+}
+
+// checkRoot returns the dimension of the nth root of d, reporting an error
+// for e when it is not a whole power
+func checkRoot(e Node, d Dimension, n int) (Dimension, error) {
+	for i := range d {
+		if d[i]%n != 0 {
+			return d, &DimensionError{Expr: String(e), Msg: fmt.Sprintf("%s is not a whole power", d)}
+		}
+		d[i] /= n
+	}
+	return d, nil
+}
+
+// checkPower returns d raised to exp, which has to give whole powers
+func checkPower(e Node, d Dimension, exp float64) (Dimension, error) {
+	for i := range d {
+		p := float64(d[i]) * exp
+		if p != math.Trunc(p) || math.Abs(p) > math.MaxInt32 {
+			return d, &DimensionError{Expr: String(e), Msg: fmt.Sprintf("%s ^ %g is not a whole power", d, exp)}
+		}
+		d[i] = int(p)
+	}
+	return d, nil
+}
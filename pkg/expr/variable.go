@@ -0,0 +1,54 @@
+package expr
+
+import (
+	"math/big"
+	"sort"
+)
+
+// Env maps variable names to their current values: a float64 or
+// quantity, the *big.Rat or *big.Float produced by the other evaluation
+// modes, or a bool
+type Env map[string]interface{}
+
+// float returns the value bound to name as a float64; quantities are in SI
+// base units
+func (env Env) float(name string) (float64, bool) {
+	switch v := env[name].(type) {
+	case float64:
+		return v, true
+	case *big.Rat:
+		f, _ := v.Float64()
+		return f, true
+	case *big.Float:
+		f, _ := v.Float64()
+		return f, true
+	case Quantity:
+		return v.Value, true
+	}
+	return 0, false
+}
+
+// Names returns the variable names in sorted order
+func (env Env) Names() []string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Variable is a named value looked up in the environment the tree is
+// evaluated with
+type Variable struct {
+	Name string
+}
+
+func (v *Variable) Eval(env Env) float64 {
+	value, _ := env.float(v.Name)
+	return value
+}
+
+func (v *Variable) ToString() string {
+	return v.Name
+}
@@ -0,0 +1,127 @@
+package expr
+
+import (
+	"sort"
+)
+
+// Composite is implemented by nodes with operands; Walk descends into them
+// in the order Children returns them
+type Composite interface {
+	Children() []Node
+}
+
+// Visitor's Visit method is called for each node encountered by Walk. If
+// the result w is not nil, Walk visits each of the children of n with w,
+// followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(n Node) (w Visitor)
+}
+
+// Walk traverses the tree rooted at n in depth-first order
+func Walk(v Visitor, n Node) {
+	if v = v.Visit(n); v == nil {
+		return
+	}
+	if c, ok := n.(Composite); ok {
+		for _, child := range c.Children() {
+			Walk(v, child)
+		}
+	}
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(n Node) Visitor {
+	if f(n) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree rooted at n in depth-first order, calling f
+// for each node and then f(nil) after its children. Returning false skips
+// the children of a node.
+func Inspect(n Node, f func(Node) bool) {
+	Walk(inspector(f), n)
+}
+
+// Variables returns the names of the variables in n, sorted and without
+// duplicates
+func Variables(n Node) []string {
+	seen := map[string]bool{}
+	var names []string
+	Inspect(n, func(n Node) bool {
+		if v, ok := n.(*Variable); ok && !seen[v.Name] {
+			seen[v.Name] = true
+			names = append(names, v.Name)
+		}
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
+
+func (bp *BinaryPlus) Children() []Node {
+	return []Node{bp.Left, bp.Right}
+}
+
+func (bp *BinaryMinus) Children() []Node {
+	return []Node{bp.Left, bp.Right}
+}
+
+func (bp *BinaryMultiply) Children() []Node {
+	return []Node{bp.Left, bp.Right}
+}
+
+func (bp *BinaryDivide) Children() []Node {
+	return []Node{bp.Left, bp.Right}
+}
+
+func (bp *BinaryModulo) Children() []Node {
+	return []Node{bp.Left, bp.Right}
+}
+
+func (bp *BinaryPower) Children() []Node {
+	return []Node{bp.Left, bp.Right}
+}
+
+func (um *UnaryMinus) Children() []Node {
+	return []Node{um.Operand}
+}
+
+func (c *Call) Children() []Node {
+	return c.Args
+}
+
+func (bp *BinaryLess) Children() []Node {
+	return []Node{bp.Left, bp.Right}
+}
+
+func (bp *BinaryLessEqual) Children() []Node {
+	return []Node{bp.Left, bp.Right}
+}
+
+func (bp *BinaryEqual) Children() []Node {
+	return []Node{bp.Left, bp.Right}
+}
+
+func (bp *BinaryAnd) Children() []Node {
+	return []Node{bp.Left, bp.Right}
+}
+
+func (bp *BinaryOr) Children() []Node {
+	return []Node{bp.Left, bp.Right}
+}
+
+func (un *UnaryNot) Children() []Node {
+	return []Node{un.Operand}
+}
+
+func (c *Conditional) Children() []Node {
+	return []Node{c.Cond, c.Then, c.Otherwise}
+}
+
+func (c *Conversion) Children() []Node {
+	return []Node{c.Operand, c.Target}
+}